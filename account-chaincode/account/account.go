@@ -6,24 +6,60 @@ package account
 import (
 	"bytes"
 	"encoding/json"
-	"fmt"
+	"errors"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/hyperledger-fabric-go-chaincodes/auth"
 	"github.com/hyperledger-fabric-go-chaincodes/query"
 	"github.com/hyperledger/fabric/core/chaincode/shim"
 	"github.com/hyperledger/fabric/protos/peer"
 )
 
-// Account structure with 4 properties. Structure tags are used by encoding/json library
+// Account structure with 16 properties. Structure tags are used by encoding/json library.
+// CustomerID references the Customer holding the account, its primary owner.
+// Owners lists every holder of the account and SigningRule ("any" when empty)
+// tells whether any of them or all of them must sign debits. AccountOwner is
+// the free-text owner name of accounts created before customers existed. It is
+// PII, stored as AccountPII in PIICollection, the public record only keeps
+// PIIHash, its hash.
+// HeldBalance is the part of AccountBalance locked in escrow, which cannot be spent.
+// Product references the catalog product ("checking" when empty) and Tier, when
+// set, overrides the transfer limits tier of the product.
+// OverdraftLimit is how far AccountBalance may go negative.
+// BalanceCommitment is set on confidential accounts, whose public record keeps
+// a zero AccountBalance: the balance is in PIICollection (see commitment.go).
+// InterestRate is yearly, in basis points. LastAccrual and InterestCarry (the
+// remainder of the last interest division) are maintained by AccrueInterest
 type Account struct {
-	ObjectType     string `json:"docType"`
-	AccountNumber  int    `json:"accountNumber"`
-	AccountBalance int    `json:"accountBalance"`
-	AccountOwner   string `json:"accountOwner"`
+	ObjectType        string  `json:"docType"`
+	AccountNumber     int     `json:"accountNumber"`
+	AccountBalance    int     `json:"accountBalance"`
+	BalanceCommitment string  `json:"balanceCommitment,omitempty"`
+	AccountOwner      string  `json:"accountOwner,omitempty"`
+	PIIHash           string  `json:"piiHash,omitempty"`
+	CustomerID        string  `json:"customerId,omitempty"`
+	Owners            []Owner `json:"owners,omitempty"`
+	SigningRule       string  `json:"signingRule,omitempty"`
+	HeldBalance       int     `json:"heldBalance"`
+	Product           string  `json:"product,omitempty"`
+	Tier              string  `json:"tier,omitempty"`
+	OverdraftLimit    int     `json:"overdraftLimit,omitempty"`
+	InterestRate      int     `json:"interestRate,omitempty"`
+	LastAccrual       string  `json:"lastAccrual,omitempty"`
+	InterestCarry     int64   `json:"interestCarry,omitempty"`
+}
+
+// AvailableBalance - Returns the amount that can be spent, i.e. the balance not
+// held in escrow plus the overdraft limit
+func (a *Account) AvailableBalance() int {
+	return a.AccountBalance - a.HeldBalance + a.OverdraftLimit
 }
 
 // Init - creates five Accounts and stores into chaincode state
 // params: none
+// transient: "piiSalt", optional secret of at least 16 bytes salting the owner names
 func Init(stub shim.ChaincodeStubInterface, logger *shim.ChaincodeLogger) peer.Response {
 	logger.Info("Entry method: Init")
 
@@ -35,10 +71,16 @@ func Init(stub shim.ChaincodeStubInterface, logger *shim.ChaincodeLogger) peer.R
 		{ObjectType: "Account", AccountNumber: 5, AccountBalance: 1000, AccountOwner: "Marcos"},
 	}
 
-	for i := 0; i < len(accounts); i++ {
-		accountsAsBytes, _ := json.Marshal(accounts[i])
+	// Instantiation takes no transient map, so without a piiSalt the sample
+	// owner names stay in the public records, as legacy ones
+	salt, err := getTransientInput(stub, "piiSalt")
+	if err != nil {
+		logger.Info("Exit method: Init")
+		return shim.Error(err.Error())
+	}
 
-		err := stub.PutState("ACC"+strconv.Itoa(i+1), accountsAsBytes)
+	for i := 0; i < len(accounts); i++ {
+		_, err := writeAccount(stub, &accounts[i], nil, salt == "")
 		if err != nil {
 			logger.Error("Error inserting accounts:", err.Error())
 			logger.Info("Exit method: Init")
@@ -48,7 +90,7 @@ func Init(stub shim.ChaincodeStubInterface, logger *shim.ChaincodeLogger) peer.R
 		logger.Debug("pushed ACC" + strconv.Itoa(i+1) + ":", accounts[i])
 	}
 
-	err := stub.SetEvent("accounts_created", []byte("Success"))
+	err = stub.SetEvent("accounts_created", []byte("Success"))
 	if err != nil {
 		logger.Critical("Failed to set event `accounts_created`:", err.Error())
 		logger.Info("Exit method: Init")
@@ -59,18 +101,25 @@ func Init(stub shim.ChaincodeStubInterface, logger *shim.ChaincodeLogger) peer.R
 	return shim.Success(nil)
 }
 
-// Create - creates new Account and stores into chaincode state
-// params: Account idAccount, accBalance, accOwner
+// Create - creates new Account and stores into chaincode state. Restricted to
+// admins and operators
+// params: Account idAccount, accBalance, customerID, [product]
 func Create(stub shim.ChaincodeStubInterface, logger *shim.ChaincodeLogger, args []string) peer.Response {
 	logger.Info("Entry method: Create")
 	logger.Debug("Received args:", args)
 
 	var err error
 
+	err = auth.Require(stub, auth.Operator, auth.Admin)
+	if err != nil {
+		logger.Info("Exit method: Create")
+		return shim.Error(err.Error())
+	}
+
 	// Input sanitation
-	if len(args) != 3 {
+	if len(args) != 3 && len(args) != 4 {
 		logger.Info("Exit method: Create")
-		return shim.Error("incorrect number of arguments. 3 expected")
+		return shim.Error("incorrect number of arguments. 3 or 4 expected")
 	}
 	if args[0] == "" {
 		logger.Info("Exit method: Create")
@@ -99,7 +148,23 @@ func Create(stub shim.ChaincodeStubInterface, logger *shim.ChaincodeLogger, args
 		return shim.Error("2nd argument must be a numeric string")
 	}
 
-	accOwner := args[2]
+	// Check the customer exists
+	customer, err := getCustomer(stub, args[2])
+	if err != nil {
+		logger.Info("Exit method: Create")
+		return shim.Error(err.Error())
+	}
+
+	// Check the product exists, "checking" by default
+	accProduct := DefaultProduct
+	if len(args) == 4 && args[3] != "" {
+		accProduct = args[3]
+	}
+	product, err := getProduct(stub, accProduct)
+	if err != nil {
+		logger.Info("Exit method: Create")
+		return shim.Error(err.Error())
+	}
 
 	// Get Account state and check if it already exists
 	AccountAsBytes, err := stub.GetState("ACC" + accNumberAsStr)
@@ -113,18 +178,24 @@ func Create(stub shim.ChaincodeStubInterface, logger *shim.ChaincodeLogger, args
 
 	// Create Account object and marshal to JSON
 	objectType := "Account"
-	account := &Account{objectType, accNumber, accBalance, accOwner}
-	accountJSONasBytes, err := json.Marshal(account)
-	if err != nil {
-		logger.Info("Exit method: Create")
-		return shim.Error("Cannot marshal Account: " + err.Error())
+	account := &Account{ObjectType: objectType, AccountNumber: accNumber, AccountBalance: accBalance, CustomerID: customer.ID, Product: product.ID}
+	account.Owners = []Owner{{CustomerID: customer.ID, Role: RolePrimary}}
+
+	// Interest-bearing products start accruing at creation
+	if product.InterestRate > 0 {
+		now, err := accrualTime(stub)
+		if err != nil {
+			logger.Info("Exit method: Create")
+			return shim.Error(err.Error())
+		}
+		account.InterestRate, account.LastAccrual = product.InterestRate, now.Format(time.RFC3339)
 	}
 
-	// Save Account to state
-	err = stub.PutState("ACC"+accNumberAsStr, accountJSONasBytes)
+	// Save Account to state and index it
+	_, err = putAccount(stub, account)
 	if err != nil {
 		logger.Info("Exit method: Create")
-		return shim.Error("Failed to put state of account: " + err.Error())
+		return shim.Error(err.Error())
 	}
 
 	// Account saved and indexed. Return success
@@ -139,14 +210,30 @@ func Create(stub shim.ChaincodeStubInterface, logger *shim.ChaincodeLogger, args
 	return shim.Success(nil)
 }
 
-// GetAll - Get all the existing accounts
-// params: none needed
-func GetAll(stub shim.ChaincodeStubInterface, logger *shim.ChaincodeLogger) peer.Response {
+// GetAll - Get all the existing accounts. A truncated response is continued by
+// passing back its bookmark
+// param: [Bookmark]
+func GetAll(stub shim.ChaincodeStubInterface, logger *shim.ChaincodeLogger, args []string) peer.Response {
 	logger.Info("Entry method: GetAll")
 	var err error
 
-	// Get Account state and check if it exists
-	accountsIterator, err := stub.GetStateByRange("", "")
+	// Input sanitation
+	if len(args) > 1 {
+		logger.Info("Exit method: GetAll")
+		return shim.Error("Incorrect number of arguments. At most 1 expected")
+	}
+	startKey := "ACC"
+	if len(args) == 1 && args[0] != "" {
+		if !strings.HasPrefix(args[0], "ACC") {
+			logger.Info("Exit method: GetAll")
+			return shim.Error("Bookmark is not an account key")
+		}
+		startKey = args[0]
+	}
+
+	// Get Account state and check if it exists. The range is bounded to the
+	// ACC key prefix so that configuration keys are not listed as accounts
+	accountsIterator, err := stub.GetStateByRange(startKey, "ACD")
 	if err != nil {
 		logger.Info("Exit method: GetAll")
 		return shim.Error("Cannot get ledger state: " + err.Error())
@@ -162,13 +249,6 @@ func GetAll(stub shim.ChaincodeStubInterface, logger *shim.ChaincodeLogger) peer
 
 	logger.Debug("queryResults: " + string(queryResults[:]))
 
-	err = stub.SetEvent("get_all_accounts", []byte("Success"))
-	if err != nil {
-		logger.Critical("Failed to set event `get_all_accounts`: " + err.Error())
-		logger.Info("Exit method: GetAll")
-		return shim.Error("Failed to set event `get_all_accounts`: " + err.Error())
-	}
-
 	logger.Info("Exit method: GetAll")
 	return shim.Success(queryResults)
 }
@@ -198,86 +278,155 @@ func GetByNumber(stub shim.ChaincodeStubInterface, logger *shim.ChaincodeLogger,
 	accNumber := args[0]
 
 	// Get Account state and check if it exists
-	accountAsBytes, err := stub.GetState("ACC" + accNumber)
+	account, err := readAccount(stub, accNumber)
 	if err != nil {
 		logger.Info("Exit method: GetByNumber")
-		return shim.Error("Failed to fetch account ACC" + accNumber + " from ledger: " + err.Error())
-	} else if accountAsBytes == nil {
-		logger.Info("Exit method: GetByNumber")
-		return shim.Error("Account ACC" + accNumber + " does not exist")
+		return shim.Error(err.Error())
+	}
+
+	// Only members of the collection get the owner name and confidential balance
+	if piiAuthorized(stub) {
+		if account.PIIHash != "" {
+			pii := AccountPII{}
+			_, err = getPII(stub, "ACC"+accNumber, &pii)
+			if err != nil {
+				logger.Info("Exit method: GetByNumber")
+				return shim.Error(err.Error())
+			}
+			account.AccountOwner = pii.AccountOwner
+		}
+		err = loadBalance(stub, account)
+		if err != nil {
+			logger.Info("Exit method: GetByNumber")
+			return shim.Error(err.Error())
+		}
 	}
 
-	err = stub.SetEvent("get_account_by_number", []byte("Success"))
+	accountAsBytes, err := json.Marshal(account)
 	if err != nil {
-		logger.Critical("Failed to set event `get_account_by_number`: " + err.Error())
 		logger.Info("Exit method: GetByNumber")
-		return shim.Error("Failed to set event `get_account_by_number`: " + err.Error())
+		return shim.Error("Cannot marshal Account: " + err.Error())
 	}
 
 	logger.Info("Exit method: GetByNumber")
 	return shim.Success(accountAsBytes)
 }
 
-// GetByOwner - Queries account by the owner name
-// param: accountOwner
-func GetByOwner(stub shim.ChaincodeStubInterface, logger *shim.ChaincodeLogger, args []string) peer.Response {
-	logger.Info("Entry method: GetByOwner")
+// GetByCustomer - Queries the accounts of a customer, joint accounts included
+// param: CustomerID
+func GetByCustomer(stub shim.ChaincodeStubInterface, logger *shim.ChaincodeLogger, args []string) peer.Response {
+	logger.Info("Entry method: GetByCustomer")
 	logger.Debug("Received args:", args)
 
 	// Input sanitation
 	if len(args) != 1 {
+		logger.Info("Exit method: GetByCustomer")
 		return shim.Error("Incorrect number of arguments. 1 expected")
 	}
 	if args[0] == "" {
+		logger.Info("Exit method: GetByCustomer")
 		return shim.Error("Argument must be a non-empty string")
 	}
 
-	// Mapping arg to variable
-	accOwner := args[0]
+	queryResults, err := heldAccounts(stub, nil, []string{args[0]})
+	if err != nil {
+		logger.Info("Exit method: GetByCustomer")
+		return shim.Error("Cannot get query results: " + err.Error())
+	}
 
-	// Construct query string using account owner name
-	queryString := fmt.Sprintf("{\"selector\":{\"docType\":\"Account\",\"accountOwner\":\"%s\"}}", accOwner)
-	logger.Debug("Query string:", queryString)
+	logger.Info("Exit method: GetByCustomer")
+	return shim.Success(queryResults)
+}
 
-	// Use package query to query couchdb and format the result
-	queryResults, err := query.GetQueryResultForQueryString(stub, queryString)
+// SearchByOwner - Searches accounts by owner name: accounts (joint accounts
+// included) of the customers with that legal name, and accounts created with
+// that free-text owner name
+// param: name
+func SearchByOwner(stub shim.ChaincodeStubInterface, logger *shim.ChaincodeLogger, args []string) peer.Response {
+	logger.Info("Entry method: SearchByOwner")
+	logger.Debug("Received args:", args)
+
+	// Input sanitation
+	if len(args) != 1 {
+		logger.Info("Exit method: SearchByOwner")
+		return shim.Error("Incorrect number of arguments. 1 expected")
+	}
+	if args[0] == "" {
+		logger.Info("Exit method: SearchByOwner")
+		return shim.Error("Argument must be a non-empty string")
+	}
+
+	// Owner names are only readable by the members of the collection
+	if !piiAuthorized(stub) {
+		logger.Info("Exit method: SearchByOwner")
+		return shim.Error("Organization not authorized to read owner names")
+	}
+
+	// Find the customers with that legal name and the accounts with that owner name
+	customerIDs, err := customersNamed(stub, args[0])
 	if err != nil {
-		logger.Info("Exit method: GetByOwner")
-		return shim.Error("Cannot get query results: " + err.Error())
+		logger.Info("Exit method: SearchByOwner")
+		return shim.Error(err.Error())
+	}
+	accNumbers, err := accountsOwnedBy(stub, args[0])
+	if err != nil {
+		logger.Info("Exit method: SearchByOwner")
+		return shim.Error(err.Error())
 	}
 
-	err = stub.SetEvent("get_account_by_owner", []byte("Success"))
+	// Find those accounts and the accounts of the customers
+	queryResults, err := heldAccounts(stub, accNumbers, customerIDs)
 	if err != nil {
-		logger.Critical("Failed to set event `get_account_by_owner`: " + err.Error())
-		logger.Info("Exit method: GetByOwner")
-		return shim.Error("Failed to set event `get_account_by_owner`: " + err.Error())
+		logger.Info("Exit method: SearchByOwner")
+		return shim.Error("Cannot get query results: " + err.Error())
 	}
 
-	logger.Info("Exit method: GetByOwner")
+	logger.Info("Exit method: SearchByOwner")
 	return shim.Success(queryResults)
 }
 
-// Update - Updates (rewrites) an account
-// param: Account JSON as bytes
+// Update - Updates (rewrites) an existing account. Its customer, owners,
+// signing rule, balances, product, overdraft limit and interest are kept, so
+// only its owner name and tier can change. Restricted to admins and operators
+// param: Account JSON as bytes, or
+// transient: "account", Account JSON, required to change the owner name
 func Update(stub shim.ChaincodeStubInterface, logger *shim.ChaincodeLogger, args []string) peer.Response {
 	logger.Info("Entry method: Update")
 	logger.Debug("Received args:", args)
 
 	var err error
 
+	err = auth.Require(stub, auth.Operator, auth.Admin)
+	if err != nil {
+		logger.Info("Exit method: Update")
+		return shim.Error(err.Error())
+	}
+
+	// Mapping input to variable
+	accAsString, err := getTransientInput(stub, "account")
+	if err != nil {
+		logger.Info("Exit method: Update")
+		return shim.Error(err.Error())
+	}
+	fromArgs := accAsString == ""
+
 	// Input sanitation
-	if len(args) != 1 {
+	if fromArgs && len(args) != 1 {
 		logger.Info("Exit method: Update")
 		return shim.Error("Incorrect number of arguments. 1 expected")
 	}
-	if args[0] == "" {
+	if !fromArgs && len(args) != 0 {
+		logger.Info("Exit method: Update")
+		return shim.Error("Incorrect number of arguments. None expected with a transient account")
+	}
+	if fromArgs {
+		accAsString = args[0]
+	}
+	if accAsString == "" {
 		logger.Info("Exit method: Update")
 		return shim.Error("Argument must be a non-empty string")
 	}
 
-	// Mapping arg to variable
-	accAsString := args[0]
-
 	// Validating string input
 	var accObject Account
 	err = json.Unmarshal([]byte(accAsString), &accObject)
@@ -285,13 +434,40 @@ func Update(stub shim.ChaincodeStubInterface, logger *shim.ChaincodeLogger, args
 		logger.Info("Exit method: Update")
 		return shim.Error("Account not valid as json object: " + err.Error())
 	}
+	if fromArgs && accObject.AccountOwner != "" {
+		logger.Info("Exit method: Update")
+		return shim.Error("accountOwner must be given in the transient map as \"account\"")
+	}
+	err = keepOwnership(stub, &accObject)
+	if err != nil {
+		logger.Info("Exit method: Update")
+		return shim.Error(err.Error())
+	}
+	err = keepConfidential(stub, &accObject)
+	if err != nil {
+		logger.Info("Exit method: Update")
+		return shim.Error(err.Error())
+	}
+	if fromArgs && accObject.Confidential() {
+		logger.Info("Exit method: Update")
+		return shim.Error("Confidential account must be given in the transient map as \"account\"")
+	}
+	err = requireBalanceUnchanged(stub, &accObject)
+	if err != nil {
+		logger.Info("Exit method: Update")
+		return shim.Error(err.Error())
+	}
+	err = keepManaged(stub, &accObject)
+	if err != nil {
+		logger.Info("Exit method: Update")
+		return shim.Error(err.Error())
+	}
 
 	// Update (rewrite) Account
-	accNumber := strconv.Itoa(accObject.AccountNumber)
-	err = stub.PutState("ACC"+accNumber, []byte(accAsString))
+	_, err = putAccount(stub, &accObject)
 	if err != nil {
 		logger.Info("Exit method: Update")
-		return shim.Error("Failed to update ACC" + accNumber + ": " + err.Error())
+		return shim.Error(err.Error())
 	}
 
 	err = stub.SetEvent("update_account", []byte("Success"))
@@ -305,7 +481,7 @@ func Update(stub shim.ChaincodeStubInterface, logger *shim.ChaincodeLogger, args
 	return shim.Success(nil)
 }
 
-// Delete - Delete account based on its number
+// Delete - Delete account based on its number. Restricted to admins and operators
 // param: AccountNumber
 func Delete(stub shim.ChaincodeStubInterface, logger *shim.ChaincodeLogger, args []string) peer.Response {
 	logger.Info("Entry method: Delete")
@@ -313,6 +489,12 @@ func Delete(stub shim.ChaincodeStubInterface, logger *shim.ChaincodeLogger, args
 
 	var err error
 
+	err = auth.Require(stub, auth.Operator, auth.Admin)
+	if err != nil {
+		logger.Info("Exit method: Delete")
+		return shim.Error(err.Error())
+	}
+
 	// Input sanitation
 	if args[0] == "" {
 		logger.Info("Exit method: Delete")
@@ -328,21 +510,37 @@ func Delete(stub shim.ChaincodeStubInterface, logger *shim.ChaincodeLogger, args
 	accNumber := args[0]
 
 	// Get Account state and check if it exists
-	accountAsBytes, err := stub.GetState("ACC" + accNumber)
+	account, err := readAccount(stub, accNumber)
 	if err != nil {
 		logger.Info("Exit method: Delete")
-		return shim.Error("Failed to fetch account ACC" + accNumber + " from ledger: " + err.Error())
-	} else if accountAsBytes == nil {
-		logger.Info("Exit method: Delete")
-		return shim.Error("Account ACC" + accNumber + " does not exist")
+		return shim.Error(err.Error())
 	}
 
-	// Remove the account from chaincode state
+	// Remove the account, its indexes, owner name and balance, from chaincode state
+	err = unindexAccount(stub, account)
+	if err != nil {
+		logger.Info("Exit method: Delete")
+		return shim.Error(err.Error())
+	}
 	err = stub.DelState("ACC" + accNumber)
 	if err != nil {
 		logger.Info("Exit method: Delete")
 		return shim.Error("Failed to delete state: " + err.Error())
 	}
+	privateKeys := []string{}
+	if account.PIIHash != "" {
+		privateKeys = append(privateKeys, "ACC"+accNumber)
+	}
+	if account.Confidential() {
+		privateKeys = append(privateKeys, "BAL"+accNumber, "BKY"+accNumber)
+	}
+	for _, key := range privateKeys {
+		err = stub.DelPrivateData(PIICollection, key)
+		if err != nil {
+			logger.Info("Exit method: Delete")
+			return shim.Error("Failed to delete private data: " + err.Error())
+		}
+	}
 
 	err = stub.SetEvent("delete_account", []byte("Success"))
 	if err != nil {
@@ -430,13 +628,277 @@ func GetHistoryByAccNumber(stub shim.ChaincodeStubInterface, logger *shim.Chainc
 		return shim.Error("Cannot find account history. ACC" + accNumber + " does not exist")
 	}
 
-	err = stub.SetEvent("get_history", []byte("Success"))
-	if err != nil {
-		logger.Critical("Failed to set event `get_history`: " + err.Error())
-		logger.Info("Exit method: GetHistory")
-		return shim.Error("Failed to set event `get_history`: " + err.Error())
+	logger.Info("Exit method: GetHistory")
+	return shim.Success(b.Bytes())
+}
+
+// GetManyByNumber - Performs a query for several accounts at once. The result is
+// a JSON array in the order of the arguments, holding null for missing accounts
+// params: AccountNumber, AccountNumber, ...
+func GetManyByNumber(stub shim.ChaincodeStubInterface, logger *shim.ChaincodeLogger, args []string) peer.Response {
+	logger.Info("Entry method: GetManyByNumber")
+	logger.Debug("Received args:", args)
+
+	var b bytes.Buffer
+
+	// Input sanitation
+	if len(args) == 0 {
+		logger.Info("Exit method: GetManyByNumber")
+		return shim.Error("Incorrect number of arguments. At least 1 expected")
 	}
 
-	logger.Info("Exit method: GetHistory")
+	b.WriteString("[")
+	for i, accNumber := range args {
+		_, err := strconv.Atoi(accNumber)
+		if err != nil {
+			logger.Info("Exit method: GetManyByNumber")
+			return shim.Error("Account number must be numeric string: " + accNumber)
+		}
+
+		// Get Account state, null when it does not exist
+		accountAsBytes, err := stub.GetState("ACC" + accNumber)
+		if err != nil {
+			logger.Info("Exit method: GetManyByNumber")
+			return shim.Error("Failed to fetch account ACC" + accNumber + " from ledger: " + err.Error())
+		}
+
+		// Confidential accounts are returned with their balance
+		if accountAsBytes != nil {
+			accountAsBytes, err = withBalance(stub, accountAsBytes)
+			if err != nil {
+				logger.Info("Exit method: GetManyByNumber")
+				return shim.Error(err.Error())
+			}
+		}
+
+		if i > 0 {
+			b.WriteString(",")
+		}
+		if accountAsBytes == nil {
+			b.WriteString("null")
+		} else {
+			b.Write(accountAsBytes)
+		}
+	}
+	b.WriteString("]")
+
+	logger.Info("Exit method: GetManyByNumber")
 	return shim.Success(b.Bytes())
 }
+
+// UpdateMany - Updates (rewrites) several existing accounts at once. Their
+// customers, owners and signing rules are kept. Only for transactions proposed
+// to the transfer chaincode
+// param: JSON array of Accounts
+func UpdateMany(stub shim.ChaincodeStubInterface, logger *shim.ChaincodeLogger, args []string) peer.Response {
+	logger.Info("Entry method: UpdateMany")
+	logger.Debug("Received args:", args)
+
+	var err error
+
+	// Balances only change through transfers
+	if !proposedTo(stub, transferChaincode) {
+		logger.Info("Exit method: UpdateMany")
+		return shim.Error("UpdateMany can only be invoked by " + transferChaincode)
+	}
+
+	// Input sanitation
+	if len(args) != 1 {
+		logger.Info("Exit method: UpdateMany")
+		return shim.Error("Incorrect number of arguments. 1 expected")
+	}
+
+	// Validating string input
+	var accounts []Account
+	err = json.Unmarshal([]byte(args[0]), &accounts)
+	if err != nil {
+		logger.Info("Exit method: UpdateMany")
+		return shim.Error("Accounts not valid as json array: " + err.Error())
+	}
+
+	// Update (rewrite) every Account
+	for i := range accounts {
+		if accounts[i].AccountOwner != "" {
+			// Legacy accounts still carry their owner name, passed back unchanged
+			stored, err := readAccount(stub, strconv.Itoa(accounts[i].AccountNumber))
+			if err != nil {
+				logger.Info("Exit method: UpdateMany")
+				return shim.Error(err.Error())
+			}
+			if stored.AccountOwner != accounts[i].AccountOwner {
+				logger.Info("Exit method: UpdateMany")
+				return shim.Error("accountOwner cannot be updated by UpdateMany")
+			}
+		}
+		err = keepOwnership(stub, &accounts[i])
+		if err != nil {
+			logger.Info("Exit method: UpdateMany")
+			return shim.Error(err.Error())
+		}
+		err = keepConfidential(stub, &accounts[i])
+		if err != nil {
+			logger.Info("Exit method: UpdateMany")
+			return shim.Error(err.Error())
+		}
+		err = accrueBeforeChange(stub, &accounts[i])
+		if err != nil {
+			logger.Info("Exit method: UpdateMany")
+			return shim.Error(err.Error())
+		}
+		_, err = putAccount(stub, &accounts[i])
+		if err != nil {
+			logger.Info("Exit method: UpdateMany")
+			return shim.Error(err.Error())
+		}
+	}
+
+	err = stub.SetEvent("update_accounts", []byte("Success"))
+	if err != nil {
+		logger.Critical("Failed to set event `update_accounts`: " + err.Error())
+		logger.Info("Exit method: UpdateMany")
+		return shim.Error("Failed to set event `update_accounts`: " + err.Error())
+	}
+
+	logger.Info("Exit method: UpdateMany")
+	return shim.Success(nil)
+}
+
+// getAccount - Reads an account by its number, with the balance when it is
+// confidential
+func getAccount(stub shim.ChaincodeStubInterface, accNumber string) (*Account, error) {
+	account, err := readAccount(stub, accNumber)
+	if err != nil {
+		return nil, err
+	}
+	err = loadBalance(stub, account)
+	if err != nil {
+		return nil, err
+	}
+	return account, nil
+}
+
+// readAccount - Reads the public record of an account by its number
+func readAccount(stub shim.ChaincodeStubInterface, accNumber string) (*Account, error) {
+	if _, err := strconv.Atoi(accNumber); err != nil {
+		return nil, errors.New("Account number must be numeric string")
+	}
+
+	accountAsBytes, err := stub.GetState("ACC" + accNumber)
+	if err != nil {
+		return nil, errors.New("Failed to fetch account ACC" + accNumber + " from ledger: " + err.Error())
+	} else if accountAsBytes == nil {
+		return nil, errors.New("Account ACC" + accNumber + " does not exist")
+	}
+
+	account := &Account{}
+	err = json.Unmarshal(accountAsBytes, account)
+	if err != nil {
+		return nil, errors.New("Cannot unmarshal account ACC" + accNumber + ": " + err.Error())
+	}
+	return account, nil
+}
+
+// keepManaged - Carries the stored balances, product, overdraft limit and
+// interest of an account over to a rewrite of it, which can only change them
+// through transfers, SetOverdraftLimit, SetInterestRate and AccrueInterest.
+// The balance of a confidential account is not in its public record, see
+// requireBalanceUnchanged. The account must exist
+func keepManaged(stub shim.ChaincodeStubInterface, account *Account) error {
+	stored, err := readAccount(stub, strconv.Itoa(account.AccountNumber))
+	if err != nil {
+		return err
+	}
+	if !stored.Confidential() {
+		account.AccountBalance = stored.AccountBalance
+	}
+	account.HeldBalance = stored.HeldBalance
+	account.Product = stored.Product
+	account.OverdraftLimit = stored.OverdraftLimit
+	account.InterestRate = stored.InterestRate
+	account.LastAccrual = stored.LastAccrual
+	account.InterestCarry = stored.InterestCarry
+	return nil
+}
+
+// putAccount - Writes an account as ACC<accountNumber> and returns it as JSON.
+// A given owner name is moved into PIICollection, only its hash is kept, and so
+// is the balance of a confidential account, only its commitment is kept
+func putAccount(stub shim.ChaincodeStubInterface, account *Account) ([]byte, error) {
+	return writeAccount(stub, account, nil, false)
+}
+
+// writeAccount - Writes an account like putAccount and updates its indexes. A
+// given balanceKey salts the balance commitment instead of the stored key of
+// the account. Without a piiSalt, publicOwner keeps a new owner name in the
+// public record instead of failing
+func writeAccount(stub shim.ChaincodeStubInterface, account *Account, balanceKey []byte, publicOwner bool) ([]byte, error) {
+	accNumber := strconv.Itoa(account.AccountNumber)
+	storedAsBytes, err := stub.GetState("ACC" + accNumber)
+	if err != nil {
+		return nil, errors.New("Failed to fetch account ACC" + accNumber + " from ledger: " + err.Error())
+	}
+	var stored *Account
+	if storedAsBytes != nil {
+		stored = &Account{}
+		err = json.Unmarshal(storedAsBytes, stored)
+		if err != nil {
+			return nil, errors.New("Cannot unmarshal account ACC" + accNumber + ": " + err.Error())
+		}
+	}
+	err = indexAccount(stub, stored, account)
+	if err != nil {
+		return nil, err
+	}
+
+	public := *account
+	if balanceKey != nil || public.Confidential() {
+		commitment, err := putBalance(stub, public.AccountNumber, public.AccountBalance, balanceKey)
+		if err != nil {
+			return nil, err
+		}
+		public.AccountBalance = 0
+		public.BalanceCommitment = commitment
+	}
+	if public.AccountOwner != "" {
+		current := AccountPII{}
+		if stored != nil && stored.PIIHash != "" {
+			_, err = getPII(stub, "ACC"+accNumber, &current)
+			if err != nil {
+				return nil, err
+			}
+		}
+		salt, err := piiSalt(stub, "ACC"+accNumber, current.Salt)
+		if err != nil {
+			return nil, err
+		}
+
+		// An owner name written before PIICollection existed stays in the public
+		// record while unchanged, until a write with a piiSalt moves it
+		legacy := stored != nil && stored.PIIHash == "" && stored.AccountOwner == public.AccountOwner
+		if salt == "" && !legacy && !publicOwner {
+			return nil, errors.New("piiSalt must be given in the transient map to write the owner name of ACC" + accNumber)
+		}
+		if salt != "" {
+			piiHash, err := putPII(stub, "ACC"+accNumber, AccountPII{
+				ObjectType:    "AccountPII",
+				AccountNumber: public.AccountNumber,
+				AccountOwner:  public.AccountOwner,
+				Salt:          salt,
+			})
+			if err != nil {
+				return nil, err
+			}
+			public.AccountOwner = ""
+			public.PIIHash = piiHash
+		}
+	}
+	accountAsBytes, err := json.Marshal(public)
+	if err != nil {
+		return nil, errors.New("Cannot marshal Account: " + err.Error())
+	}
+	err = stub.PutState("ACC"+accNumber, accountAsBytes)
+	if err != nil {
+		return nil, errors.New("Failed to update ACC" + accNumber + ": " + err.Error())
+	}
+	return accountAsBytes, nil
+}
@@ -0,0 +1,106 @@
+package account
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/hyperledger-fabric-go-chaincodes/auth"
+	"github.com/hyperledger/fabric/core/chaincode/lib/cid"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/peer"
+)
+
+// readAuditKey - state key holding the read audit trail switch
+const readAuditKey = "CFG_READ_AUDIT"
+
+// ReadAudit structure describing a read performed on the ledger. When the audit
+// trail is enabled it is marshaled into the Message field of query responses,
+// leaving the Payload untouched for callers such as other chaincodes
+type ReadAudit struct {
+	TxID      string   `json:"txId"`
+	Timestamp string   `json:"timestamp"`
+	MSPID     string   `json:"mspId"`
+	Function  string   `json:"function"`
+	Args      []string `json:"args"`
+}
+
+// SetReadAudit - Enables or disables the audit trail attached to query responses.
+// Restricted to admins
+// param: "true" or "false"
+func SetReadAudit(stub shim.ChaincodeStubInterface, logger *shim.ChaincodeLogger, args []string) peer.Response {
+	logger.Info("Entry method: SetReadAudit")
+	logger.Debug("Received args:", args)
+
+	err := auth.Require(stub, auth.Admin)
+	if err != nil {
+		logger.Info("Exit method: SetReadAudit")
+		return shim.Error(err.Error())
+	}
+
+	// Input sanitation
+	if len(args) != 1 {
+		logger.Info("Exit method: SetReadAudit")
+		return shim.Error("Incorrect number of arguments. 1 expected")
+	}
+	enabled, err := strconv.ParseBool(args[0])
+	if err != nil {
+		logger.Info("Exit method: SetReadAudit")
+		return shim.Error("Argument must be \"true\" or \"false\"")
+	}
+
+	err = stub.PutState(readAuditKey, []byte(strconv.FormatBool(enabled)))
+	if err != nil {
+		logger.Info("Exit method: SetReadAudit")
+		return shim.Error("Failed to put state of read audit switch: " + err.Error())
+	}
+
+	err = stub.SetEvent("read_audit_updated", []byte(strconv.FormatBool(enabled)))
+	if err != nil {
+		logger.Critical("Failed to set event `read_audit_updated`: " + err.Error())
+		logger.Info("Exit method: SetReadAudit")
+		return shim.Error("Failed to set event `read_audit_updated`: " + err.Error())
+	}
+
+	logger.Info("Exit method: SetReadAudit")
+	return shim.Success(nil)
+}
+
+// AuditRead - Attaches a ReadAudit record to a successful query response when
+// the audit trail is enabled. Failures to build the record never fail the query
+func AuditRead(stub shim.ChaincodeStubInterface, logger *shim.ChaincodeLogger, function string, args []string, response peer.Response) peer.Response {
+	if response.Status != shim.OK {
+		return response
+	}
+
+	switchAsBytes, err := stub.GetState(readAuditKey)
+	if err != nil {
+		logger.Warning("Cannot read audit trail switch: " + err.Error())
+		return response
+	}
+	if enabled, _ := strconv.ParseBool(string(switchAsBytes)); !enabled {
+		return response
+	}
+
+	audit := ReadAudit{TxID: stub.GetTxID(), Function: function, Args: args}
+
+	timestamp, err := stub.GetTxTimestamp()
+	if err == nil {
+		audit.Timestamp = time.Unix(timestamp.Seconds, int64(timestamp.Nanos)).UTC().Format(time.RFC3339Nano)
+	}
+
+	audit.MSPID, err = cid.GetMSPID(stub)
+	if err != nil {
+		logger.Warning("Cannot get reader MSP ID: " + err.Error())
+	}
+
+	auditAsBytes, err := json.Marshal(audit)
+	if err != nil {
+		logger.Warning("Cannot marshal read audit: " + err.Error())
+		return response
+	}
+
+	logger.Notice("Read audit:", string(auditAsBytes))
+	response.Message = string(auditAsBytes)
+	return response
+}
@@ -0,0 +1,376 @@
+package account
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strconv"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger-fabric-go-chaincodes/auth"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/common"
+	"github.com/hyperledger/fabric/protos/peer"
+)
+
+// minBalanceKeyLength is the minimum length of the secret key salting the
+// balance commitments of an account
+const minBalanceKeyLength = 16
+
+// transferChaincode - name of the chaincode moving money between accounts,
+// which reads confidential balances whatever the organization of its client
+const transferChaincode = "cc-transfer"
+
+// BalanceOpening structure with the balance of a confidential account and the
+// salt opening its commitment. It is stored in PIICollection as BAL<accountNumber>
+type BalanceOpening struct {
+	ObjectType     string `json:"docType"`
+	AccountNumber  int    `json:"accountNumber"`
+	AccountBalance int    `json:"accountBalance"`
+	Salt           string `json:"salt"`
+	Commitment     string `json:"commitment"`
+}
+
+// BalanceVerification structure returned by VerifyBalance
+type BalanceVerification struct {
+	AccountNumber  int    `json:"accountNumber"`
+	AccountBalance int    `json:"accountBalance"`
+	Commitment     string `json:"commitment"`
+	Valid          bool   `json:"valid"`
+}
+
+// SetConfidentialBalance - Makes the balance of an account confidential: from
+// then on the public record only keeps a salted hash commitment of the balance,
+// which is held in PIICollection. The salts are derived from a secret key given
+// in the transient map. Restricted to admins
+// param: AccountNumber
+// transient: "balanceKey", secret key of at least 16 bytes
+func SetConfidentialBalance(stub shim.ChaincodeStubInterface, logger *shim.ChaincodeLogger, args []string) peer.Response {
+	logger.Info("Entry method: SetConfidentialBalance")
+	logger.Debug("Received args:", args)
+
+	err := auth.Require(stub, auth.Admin)
+	if err != nil {
+		logger.Info("Exit method: SetConfidentialBalance")
+		return shim.Error(err.Error())
+	}
+
+	// Input sanitation
+	if len(args) != 1 {
+		logger.Info("Exit method: SetConfidentialBalance")
+		return shim.Error("Incorrect number of arguments. 1 expected")
+	}
+	balanceKey, err := getTransientInput(stub, "balanceKey")
+	if err != nil {
+		logger.Info("Exit method: SetConfidentialBalance")
+		return shim.Error(err.Error())
+	}
+	if len(balanceKey) < minBalanceKeyLength {
+		logger.Info("Exit method: SetConfidentialBalance")
+		return shim.Error("balanceKey of at least " + strconv.Itoa(minBalanceKeyLength) + " bytes must be given in the transient map")
+	}
+
+	account, err := getAccount(stub, args[0])
+	if err != nil {
+		logger.Info("Exit method: SetConfidentialBalance")
+		return shim.Error(err.Error())
+	}
+	if account.Confidential() {
+		logger.Info("Exit method: SetConfidentialBalance")
+		return shim.Error("Balance of account ACC" + args[0] + " is already confidential")
+	}
+
+	err = stub.PutPrivateData(PIICollection, "BKY"+args[0], []byte(balanceKey))
+	if err != nil {
+		logger.Info("Exit method: SetConfidentialBalance")
+		return shim.Error("Failed to put balance key of ACC" + args[0] + ": " + err.Error())
+	}
+
+	// The key just written cannot be read back in this transaction
+	accountAsBytes, err := writeAccount(stub, account, []byte(balanceKey), false)
+	if err != nil {
+		logger.Info("Exit method: SetConfidentialBalance")
+		return shim.Error(err.Error())
+	}
+
+	err = stub.SetEvent("balance_confidential", accountAsBytes)
+	if err != nil {
+		logger.Critical("Failed to set event `balance_confidential`: " + err.Error())
+		logger.Info("Exit method: SetConfidentialBalance")
+		return shim.Error("Failed to set event `balance_confidential`: " + err.Error())
+	}
+
+	logger.Info("Exit method: SetConfidentialBalance")
+	return shim.Success(accountAsBytes)
+}
+
+// GetBalanceOpening - Queries the balance of a confidential account and the
+// salt opening its current commitment, to be handed to an auditor. Only for
+// the organizations member of PIICollection
+// param: AccountNumber
+func GetBalanceOpening(stub shim.ChaincodeStubInterface, logger *shim.ChaincodeLogger, args []string) peer.Response {
+	logger.Info("Entry method: GetBalanceOpening")
+	logger.Debug("Received args:", args)
+
+	// Input sanitation
+	if len(args) != 1 {
+		logger.Info("Exit method: GetBalanceOpening")
+		return shim.Error("Incorrect number of arguments. 1 expected")
+	}
+
+	opening, err := getBalanceOpening(stub, args[0])
+	if err != nil {
+		logger.Info("Exit method: GetBalanceOpening")
+		return shim.Error(err.Error())
+	}
+
+	openingAsBytes, err := json.Marshal(opening)
+	if err != nil {
+		logger.Info("Exit method: GetBalanceOpening")
+		return shim.Error("Cannot marshal balance opening: " + err.Error())
+	}
+
+	logger.Info("Exit method: GetBalanceOpening")
+	return shim.Success(openingAsBytes)
+}
+
+// VerifyBalance - Checks a claimed balance against the commitment in the public
+// record of a confidential account. Anyone can verify with the salt disclosed by
+// the bank (see GetBalanceOpening); without salt the stored one is used, which
+// only the organizations member of PIICollection can do
+// params: AccountNumber, Balance, [Salt]
+func VerifyBalance(stub shim.ChaincodeStubInterface, logger *shim.ChaincodeLogger, args []string) peer.Response {
+	logger.Info("Entry method: VerifyBalance")
+	logger.Debug("Received args:", args)
+
+	// Input sanitation
+	if len(args) != 2 && len(args) != 3 {
+		logger.Info("Exit method: VerifyBalance")
+		return shim.Error("Incorrect number of arguments. 2 or 3 expected")
+	}
+	balance, err := strconv.Atoi(args[1])
+	if err != nil {
+		logger.Info("Exit method: VerifyBalance")
+		return shim.Error("Balance must be a numeric string")
+	}
+
+	account, err := readAccount(stub, args[0])
+	if err != nil {
+		logger.Info("Exit method: VerifyBalance")
+		return shim.Error(err.Error())
+	}
+	if !account.Confidential() {
+		logger.Info("Exit method: VerifyBalance")
+		return shim.Error("Balance of account ACC" + args[0] + " is not confidential")
+	}
+
+	var salt string
+	if len(args) == 3 {
+		salt = args[2]
+	} else {
+		opening, err := getBalanceOpening(stub, args[0])
+		if err != nil {
+			logger.Info("Exit method: VerifyBalance")
+			return shim.Error(err.Error())
+		}
+		salt = opening.Salt
+	}
+
+	verification := BalanceVerification{
+		AccountNumber:  account.AccountNumber,
+		AccountBalance: balance,
+		Commitment:     account.BalanceCommitment,
+		Valid:          hmac.Equal([]byte(balanceCommitment(salt, balance)), []byte(account.BalanceCommitment)),
+	}
+	verificationAsBytes, err := json.Marshal(verification)
+	if err != nil {
+		logger.Info("Exit method: VerifyBalance")
+		return shim.Error("Cannot marshal balance verification: " + err.Error())
+	}
+
+	logger.Info("Exit method: VerifyBalance")
+	return shim.Success(verificationAsBytes)
+}
+
+// Confidential - Tells whether the balance of the account is only committed to
+// in its public record
+func (a *Account) Confidential() bool {
+	return a.BalanceCommitment != ""
+}
+
+// balanceCommitment - Returns the commitment to a balance: the hex SHA-256 hash
+// of the salt and the balance
+func balanceCommitment(salt string, balance int) string {
+	hash := sha256.Sum256([]byte(salt + ":" + strconv.Itoa(balance)))
+	return hex.EncodeToString(hash[:])
+}
+
+// putBalance - Writes the balance of a confidential account into PIICollection
+// with a fresh salt and returns its commitment. The salt is the HMAC of the
+// transaction ID by the balance key of the account, so every endorser derives
+// the same salt while nobody without the key can
+func putBalance(stub shim.ChaincodeStubInterface, accNumber int, balance int, balanceKey []byte) (string, error) {
+	key := strconv.Itoa(accNumber)
+	if balanceKey == nil {
+		var err error
+		balanceKey, err = stub.GetPrivateData(PIICollection, "BKY"+key)
+		if err != nil {
+			return "", errors.New("Failed to fetch balance key of ACC" + key + ": " + err.Error())
+		} else if balanceKey == nil {
+			return "", errors.New("Balance key of ACC" + key + " does not exist")
+		}
+	}
+
+	mac := hmac.New(sha256.New, balanceKey)
+	mac.Write([]byte(stub.GetTxID() + ":" + key))
+	salt := hex.EncodeToString(mac.Sum(nil))
+
+	opening := BalanceOpening{
+		ObjectType:     "BalanceOpening",
+		AccountNumber:  accNumber,
+		AccountBalance: balance,
+		Salt:           salt,
+		Commitment:     balanceCommitment(salt, balance),
+	}
+	openingAsBytes, err := json.Marshal(opening)
+	if err != nil {
+		return "", errors.New("Cannot marshal balance opening: " + err.Error())
+	}
+	err = stub.PutPrivateData(PIICollection, "BAL"+key, openingAsBytes)
+	if err != nil {
+		return "", errors.New("Failed to put balance of ACC" + key + ": " + err.Error())
+	}
+	return opening.Commitment, nil
+}
+
+// getBalanceOpening - Reads the balance opening of a confidential account.
+// Only for the organizations member of PIICollection
+func getBalanceOpening(stub shim.ChaincodeStubInterface, accNumber string) (*BalanceOpening, error) {
+	if !piiAuthorized(stub) {
+		return nil, errors.New("Organization not authorized to read the balance of ACC" + accNumber)
+	}
+	return readBalanceOpening(stub, accNumber)
+}
+
+// readBalanceOpening - Reads the balance opening of a confidential account,
+// whoever the caller is. Its balance must not be returned to unauthorized callers
+func readBalanceOpening(stub shim.ChaincodeStubInterface, accNumber string) (*BalanceOpening, error) {
+	opening := &BalanceOpening{}
+	found, err := getPII(stub, "BAL"+accNumber, opening)
+	if err != nil {
+		return nil, err
+	} else if !found {
+		return nil, errors.New("Balance of ACC" + accNumber + " is not confidential")
+	}
+	return opening, nil
+}
+
+// loadBalance - Fills the balance of a confidential account from PIICollection,
+// whoever the caller is. Queries check balanceAuthorized before returning it
+func loadBalance(stub shim.ChaincodeStubInterface, account *Account) error {
+	if !account.Confidential() {
+		return nil
+	}
+	opening, err := readBalanceOpening(stub, strconv.Itoa(account.AccountNumber))
+	if err != nil {
+		return err
+	}
+	account.AccountBalance = opening.AccountBalance
+	return nil
+}
+
+// balanceAuthorized - Tells whether the caller may read confidential balances:
+// members of PIICollection, and the transfer chaincode, which needs them to
+// move money for the clients of any organization
+func balanceAuthorized(stub shim.ChaincodeStubInterface) bool {
+	return piiAuthorized(stub) || proposedTo(stub, transferChaincode)
+}
+
+// proposedTo - Tells whether the transaction proposal was sent to a chaincode,
+// which then invoked this one. The peer runs the chaincode named in the
+// proposal header, so clients cannot claim another one
+func proposedTo(stub shim.ChaincodeStubInterface, chaincode string) bool {
+	signedProposal, err := stub.GetSignedProposal()
+	if err != nil || signedProposal == nil {
+		return false
+	}
+	proposal := &peer.Proposal{}
+	if proto.Unmarshal(signedProposal.ProposalBytes, proposal) != nil {
+		return false
+	}
+	header := &common.Header{}
+	if proto.Unmarshal(proposal.Header, header) != nil {
+		return false
+	}
+	channelHeader := &common.ChannelHeader{}
+	if proto.Unmarshal(header.ChannelHeader, channelHeader) != nil {
+		return false
+	}
+	extension := &peer.ChaincodeHeaderExtension{}
+	if proto.Unmarshal(channelHeader.Extension, extension) != nil {
+		return false
+	}
+	return extension.ChaincodeId != nil && extension.ChaincodeId.Name == chaincode
+}
+
+// withBalance - Returns the account JSON with the balance when it is
+// confidential. Only for callers authorized by balanceAuthorized
+func withBalance(stub shim.ChaincodeStubInterface, accountAsBytes []byte) ([]byte, error) {
+	account := &Account{}
+	err := json.Unmarshal(accountAsBytes, account)
+	if err != nil {
+		return nil, errors.New("Cannot unmarshal account: " + err.Error())
+	}
+	if !account.Confidential() {
+		return accountAsBytes, nil
+	}
+	if !balanceAuthorized(stub) {
+		return nil, errors.New("Organization not authorized to read the balance of ACC" + strconv.Itoa(account.AccountNumber))
+	}
+	err = loadBalance(stub, account)
+	if err != nil {
+		return nil, err
+	}
+	accountAsBytes, err = json.Marshal(account)
+	if err != nil {
+		return nil, errors.New("Cannot marshal Account: " + err.Error())
+	}
+	return accountAsBytes, nil
+}
+
+// keepConfidential - Carries the stored confidentiality of an account over to
+// a rewrite of it, which can neither make it confidential nor reveal it
+func keepConfidential(stub shim.ChaincodeStubInterface, account *Account) error {
+	accountAsBytes, err := stub.GetState("ACC" + strconv.Itoa(account.AccountNumber))
+	if err != nil {
+		return errors.New("Failed to fetch account ACC" + strconv.Itoa(account.AccountNumber) + " from ledger: " + err.Error())
+	}
+	stored := &Account{}
+	if accountAsBytes != nil {
+		err = json.Unmarshal(accountAsBytes, stored)
+		if err != nil {
+			return errors.New("Cannot unmarshal account ACC" + strconv.Itoa(account.AccountNumber) + ": " + err.Error())
+		}
+	}
+	account.BalanceCommitment = stored.BalanceCommitment
+	return nil
+}
+
+// requireBalanceUnchanged - Rejects a rewrite of a confidential account changing
+// its balance, which only transfers can do. Callers outside PIICollection read
+// a zero balance, which must not be written back as the balance
+func requireBalanceUnchanged(stub shim.ChaincodeStubInterface, account *Account) error {
+	if !account.Confidential() {
+		return nil
+	}
+	opening, err := readBalanceOpening(stub, strconv.Itoa(account.AccountNumber))
+	if err != nil {
+		return err
+	}
+	if account.AccountBalance != opening.AccountBalance {
+		return errors.New("Balance of confidential account ACC" + strconv.Itoa(account.AccountNumber) + " can only change through transfers")
+	}
+	return nil
+}
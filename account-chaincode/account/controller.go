@@ -0,0 +1,164 @@
+package account
+
+import (
+	"strings"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/peer"
+)
+
+// AccountsChaincode struct
+type AccountsChaincode struct {
+}
+
+// Logger
+var logger = shim.NewLogger("cc-account")
+
+// Init - initializes chaincode
+func (t *AccountsChaincode) Init(stub shim.ChaincodeStubInterface) peer.Response {
+	args := stub.GetStringArgs()
+	var logLevel string
+
+	// Input sanitation
+	if len(args) > 1 {
+		return shim.Error("Incorrect number of arguments. None or 1 expected")
+	}
+
+	// Input Mapping
+	if len(args) == 1 {
+		logLevel = strings.ToUpper(args[0])
+	}
+
+	// Selecting log level
+	switch logLevel {
+	case "DEBUG":
+		logger.SetLevel(shim.LogDebug)
+	case "INFO":
+		logger.SetLevel(shim.LogInfo)
+	case "NOTICE":
+		logger.SetLevel(shim.LogNotice)
+	case "WARNING":
+		logger.SetLevel(shim.LogWarning)
+	case "ERROR":
+		logger.SetLevel(shim.LogError)
+	case "CRITICAL":
+		logger.SetLevel(shim.LogCritical)
+	default:
+		logger.SetLevel(shim.LogInfo)
+		logger.Warning("Level \"" + logLevel + "\" not recognized as valid log level")
+		logger.Notice("Using default logger level \"INFO\"")
+	}
+
+	// Product catalog
+	err := InitProducts(stub, logger)
+	if err != nil {
+		logger.Error("Cannot initialize product catalog: " + err.Error())
+		return shim.Error("Cannot initialize product catalog: " + err.Error())
+	}
+
+	logger.Info("Initialized `cc-account` chaincode")
+	return shim.Success(nil)
+}
+
+// Invoke - Entry point for Invocations
+func (t *AccountsChaincode) Invoke(stub shim.ChaincodeStubInterface) peer.Response {
+	function, args := stub.GetFunctionAndParameters()
+
+	// Configuring logger
+	// logger.SetLevel(shim.LogDebug)
+	logger.Info("Chaincode invoke: function:\"" + function + "\"")
+
+	// Handle different functions
+	if response, found := t.invoke(stub, function, args); found {
+		return response
+	}
+	if response, found := t.query(stub, function, args); found {
+		return AuditRead(stub, logger, function, args, response)
+	}
+
+	// Error
+	logger.Error("Received unknown function invoke: \"" + function + "\"")
+	return shim.Error("Received unknown function invoke: \"" + function + "\"")
+}
+
+// invoke - Dispatches functions that write to the ledger and emit events
+func (t *AccountsChaincode) invoke(stub shim.ChaincodeStubInterface, function string, args []string) (peer.Response, bool) {
+	switch function {
+	case "Init":
+		return Init(stub, logger), true
+	case "Create":
+		return Create(stub, logger, args), true
+	case "Update":
+		return Update(stub, logger, args), true
+	case "UpdateMany":
+		return UpdateMany(stub, logger, args), true
+	case "Delete":
+		return Delete(stub, logger, args), true
+	case "CreateCustomer":
+		return CreateCustomer(stub, logger, args), true
+	case "UpdateCustomer":
+		return UpdateCustomer(stub, logger, args), true
+	case "AddCoOwner":
+		return AddCoOwner(stub, logger, args), true
+	case "RemoveCoOwner":
+		return RemoveCoOwner(stub, logger, args), true
+	case "SetSigningRule":
+		return SetSigningRule(stub, logger, args), true
+	case "SetReadAudit":
+		return SetReadAudit(stub, logger, args), true
+	case "SetOverdraftLimit":
+		return SetOverdraftLimit(stub, logger, args), true
+	case "SetProduct":
+		return SetProduct(stub, logger, args), true
+	case "SetInterestRate":
+		return SetInterestRate(stub, logger, args), true
+	case "AccrueInterest":
+		return AccrueInterest(stub, logger, args), true
+	case "SetConfidentialBalance":
+		return SetConfidentialBalance(stub, logger, args), true
+	case "RebuildIndexes":
+		return RebuildIndexes(stub, logger), true
+	default:
+		return peer.Response{}, false
+	}
+}
+
+// query - Dispatches read-only functions. Queries never write state nor emit events
+func (t *AccountsChaincode) query(stub shim.ChaincodeStubInterface, function string, args []string) (peer.Response, bool) {
+	switch function {
+	case "GetAll":
+		return GetAll(stub, logger, args), true
+	case "GetByNumber":
+		return GetByNumber(stub, logger, args), true
+	case "GetManyByNumber":
+		return GetManyByNumber(stub, logger, args), true
+	case "GetAvailableFunds":
+		return GetAvailableFunds(stub, logger, args), true
+	case "GetJournal":
+		return GetJournal(stub, logger, args), true
+	case "GetProducts":
+		return GetProducts(stub, logger), true
+	case "GetByCustomer":
+		return GetByCustomer(stub, logger, args), true
+	case "SearchByOwner":
+		return SearchByOwner(stub, logger, args), true
+	case "GetCustomer":
+		return GetCustomer(stub, logger, args), true
+	case "GetSigner":
+		return GetSigner(stub, logger, args), true
+	case "GetBalanceOpening":
+		return GetBalanceOpening(stub, logger, args), true
+	case "VerifyBalance":
+		return VerifyBalance(stub, logger, args), true
+	case "GetHistory":
+		return GetHistoryByAccNumber(stub, logger, args), true
+	case "GetHistoryBetween":
+		return GetHistoryBetween(stub, logger, args), true
+	case "GetBalanceAt":
+		return GetBalanceAt(stub, logger, args), true
+	case "Query":
+		return Query(stub, logger, args), true
+	default:
+		return peer.Response{}, false
+	}
+}
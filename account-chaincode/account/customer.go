@@ -0,0 +1,313 @@
+package account
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+
+	"github.com/hyperledger-fabric-go-chaincodes/auth"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/peer"
+)
+
+// Contact structure with the contact information of a customer
+type Contact struct {
+	Email   string `json:"email,omitempty"`
+	Phone   string `json:"phone,omitempty"`
+	Address string `json:"address,omitempty"`
+}
+
+// Customer structure with the holder of accounts. It is stored as CUS<id>. Only
+// the SHA-256 hash of the identity document ID is kept. MSPID and ClientID are
+// the Fabric identity of the customer, when it has one.
+// LegalName, DocumentIDHash and Contact are PII: they are stored as CustomerPII
+// in PIICollection and the public record only keeps PIIHash, their hash
+type Customer struct {
+	ObjectType     string   `json:"docType"`
+	ID             string   `json:"id"`
+	LegalName      string   `json:"legalName,omitempty"`
+	DocumentIDHash string   `json:"documentIdHash,omitempty"`
+	Contact        *Contact `json:"contact,omitempty"`
+	MSPID          string   `json:"mspId,omitempty"`
+	ClientID       string   `json:"clientId,omitempty"`
+	PIIHash        string   `json:"piiHash,omitempty"`
+}
+
+// customerInput structure accepted by CreateCustomer and UpdateCustomer. The
+// document ID is hashed and never stored
+type customerInput struct {
+	Customer
+	DocumentID string `json:"documentId"`
+}
+
+// CreateCustomer - Creates a new Customer and stores it into chaincode state,
+// its PII into PIICollection. Restricted to admins and operators
+// transient: "customer", Customer JSON with the plain "documentId"
+func CreateCustomer(stub shim.ChaincodeStubInterface, logger *shim.ChaincodeLogger, args []string) peer.Response {
+	logger.Info("Entry method: CreateCustomer")
+
+	err := auth.Require(stub, auth.Operator, auth.Admin)
+	if err != nil {
+		logger.Info("Exit method: CreateCustomer")
+		return shim.Error(err.Error())
+	}
+
+	// Input sanitation
+	if len(args) != 0 {
+		logger.Info("Exit method: CreateCustomer")
+		return shim.Error("Incorrect number of arguments. The customer must be given in the transient map")
+	}
+	customerAsString, err := getTransientInput(stub, "customer")
+	if err != nil {
+		logger.Info("Exit method: CreateCustomer")
+		return shim.Error(err.Error())
+	}
+	input, err := parseCustomer(customerAsString)
+	if err != nil {
+		logger.Info("Exit method: CreateCustomer")
+		return shim.Error(err.Error())
+	}
+	if input.DocumentID == "" {
+		logger.Info("Exit method: CreateCustomer")
+		return shim.Error("Customer documentId must be a non-empty string")
+	}
+
+	// Check if the customer already exists
+	customerAsBytes, err := stub.GetState("CUS" + input.ID)
+	if err != nil {
+		logger.Info("Exit method: CreateCustomer")
+		return shim.Error("Failed to get customer data: " + err.Error())
+	} else if customerAsBytes != nil {
+		logger.Info("Exit method: CreateCustomer")
+		return shim.Error("Customer " + input.ID + " already exists")
+	}
+
+	customer := input.Customer
+	customer.ObjectType = "Customer"
+	customer.DocumentIDHash = hashDocumentID(input.DocumentID)
+	customerAsBytes, err = putCustomer(stub, &customer)
+	if err != nil {
+		logger.Info("Exit method: CreateCustomer")
+		return shim.Error(err.Error())
+	}
+
+	err = stub.SetEvent("customer_created", []byte(customer.ID))
+	if err != nil {
+		logger.Critical("Failed to set event `customer_created`: " + err.Error())
+		logger.Info("Exit method: CreateCustomer")
+		return shim.Error("Failed to set event `customer_created`: " + err.Error())
+	}
+
+	logger.Info("Exit method: CreateCustomer")
+	return shim.Success(customerAsBytes)
+}
+
+// UpdateCustomer - Rewrites a Customer. The document ID hash is kept unless a
+// new "documentId" is given. The Fabric identity of the customer decides who
+// may debit its accounts, so only admins can change it. Restricted to admins
+// and operators
+// transient: "customer", Customer JSON
+func UpdateCustomer(stub shim.ChaincodeStubInterface, logger *shim.ChaincodeLogger, args []string) peer.Response {
+	logger.Info("Entry method: UpdateCustomer")
+
+	err := auth.Require(stub, auth.Operator, auth.Admin)
+	if err != nil {
+		logger.Info("Exit method: UpdateCustomer")
+		return shim.Error(err.Error())
+	}
+
+	// Input sanitation
+	if len(args) != 0 {
+		logger.Info("Exit method: UpdateCustomer")
+		return shim.Error("Incorrect number of arguments. The customer must be given in the transient map")
+	}
+	customerAsString, err := getTransientInput(stub, "customer")
+	if err != nil {
+		logger.Info("Exit method: UpdateCustomer")
+		return shim.Error(err.Error())
+	}
+	input, err := parseCustomer(customerAsString)
+	if err != nil {
+		logger.Info("Exit method: UpdateCustomer")
+		return shim.Error(err.Error())
+	}
+
+	previous, err := getCustomer(stub, input.ID)
+	if err == nil {
+		err = getCustomerPII(stub, previous)
+	}
+	if err != nil {
+		logger.Info("Exit method: UpdateCustomer")
+		return shim.Error(err.Error())
+	}
+
+	customer := input.Customer
+	customer.ObjectType = "Customer"
+	customer.DocumentIDHash = previous.DocumentIDHash
+	if input.DocumentID != "" {
+		customer.DocumentIDHash = hashDocumentID(input.DocumentID)
+	}
+	if auth.Require(stub, auth.Admin) != nil {
+		customer.MSPID = previous.MSPID
+		customer.ClientID = previous.ClientID
+	}
+	customerAsBytes, err := putCustomer(stub, &customer)
+	if err != nil {
+		logger.Info("Exit method: UpdateCustomer")
+		return shim.Error(err.Error())
+	}
+
+	err = stub.SetEvent("customer_updated", []byte(customer.ID))
+	if err != nil {
+		logger.Critical("Failed to set event `customer_updated`: " + err.Error())
+		logger.Info("Exit method: UpdateCustomer")
+		return shim.Error("Failed to set event `customer_updated`: " + err.Error())
+	}
+
+	logger.Info("Exit method: UpdateCustomer")
+	return shim.Success(customerAsBytes)
+}
+
+// GetCustomer - Queries a customer by its ID. Its PII is only returned to the
+// organizations member of PIICollection
+// param: CustomerID
+func GetCustomer(stub shim.ChaincodeStubInterface, logger *shim.ChaincodeLogger, args []string) peer.Response {
+	logger.Info("Entry method: GetCustomer")
+	logger.Debug("Received args:", args)
+
+	// Input sanitation
+	if len(args) != 1 {
+		logger.Info("Exit method: GetCustomer")
+		return shim.Error("Incorrect number of arguments. 1 expected")
+	}
+
+	customer, err := getCustomer(stub, args[0])
+	if err != nil {
+		logger.Info("Exit method: GetCustomer")
+		return shim.Error(err.Error())
+	}
+
+	// Only members of the collection get the PII
+	if piiAuthorized(stub) {
+		err = getCustomerPII(stub, customer)
+		if err != nil {
+			logger.Info("Exit method: GetCustomer")
+			return shim.Error(err.Error())
+		}
+	}
+
+	customerAsBytes, err := json.Marshal(customer)
+	if err != nil {
+		logger.Info("Exit method: GetCustomer")
+		return shim.Error("Cannot marshal customer: " + err.Error())
+	}
+
+	logger.Info("Exit method: GetCustomer")
+	return shim.Success(customerAsBytes)
+}
+
+// parseCustomer - Unmarshals and checks the customer input of create and update
+func parseCustomer(customerAsString string) (*customerInput, error) {
+	if customerAsString == "" {
+		return nil, errors.New("Customer must be given in the transient map as \"customer\"")
+	}
+	input := &customerInput{}
+	err := json.Unmarshal([]byte(customerAsString), input)
+	if err != nil {
+		return nil, errors.New("Customer not valid as json object: " + err.Error())
+	}
+	if input.ID == "" {
+		return nil, errors.New("Customer id must be a non-empty string")
+	}
+	if input.LegalName == "" {
+		return nil, errors.New("Customer legalName must be a non-empty string")
+	}
+	return input, nil
+}
+
+// getCustomer - Reads a customer by its ID
+func getCustomer(stub shim.ChaincodeStubInterface, id string) (*Customer, error) {
+	customerAsBytes, err := stub.GetState("CUS" + id)
+	if err != nil {
+		return nil, errors.New("Failed to fetch customer " + id + " from ledger: " + err.Error())
+	} else if customerAsBytes == nil {
+		return nil, errors.New("Customer " + id + " does not exist")
+	}
+
+	customer := &Customer{}
+	err = json.Unmarshal(customerAsBytes, customer)
+	if err != nil {
+		return nil, errors.New("Cannot unmarshal customer " + id + ": " + err.Error())
+	}
+	return customer, nil
+}
+
+// getCustomerPII - Fills the PII of a customer from PIICollection
+func getCustomerPII(stub shim.ChaincodeStubInterface, customer *Customer) error {
+	pii := CustomerPII{}
+	found, err := getPII(stub, "CUS"+customer.ID, &pii)
+	if err != nil || !found {
+		return err
+	}
+	customer.LegalName = pii.LegalName
+	customer.DocumentIDHash = pii.DocumentIDHash
+	customer.Contact = pii.Contact
+	return nil
+}
+
+// putCustomer - Writes the PII of a customer into PIICollection and the rest,
+// with the PII hash, as CUS<id>, and indexes its legal name. Returns the public
+// record as JSON
+func putCustomer(stub shim.ChaincodeStubInterface, customer *Customer) ([]byte, error) {
+	previous := CustomerPII{}
+	_, err := getPII(stub, "CUS"+customer.ID, &previous)
+	if err != nil {
+		return nil, err
+	}
+	err = indexCustomer(stub, customer.ID, previous.LegalName, customer.LegalName)
+	if err != nil {
+		return nil, err
+	}
+
+	salt, err := piiSalt(stub, "CUS"+customer.ID, previous.Salt)
+	if err != nil {
+		return nil, err
+	} else if salt == "" {
+		return nil, errors.New("piiSalt must be given in the transient map to register customer " + customer.ID)
+	}
+	piiHash, err := putPII(stub, "CUS"+customer.ID, CustomerPII{
+		ObjectType:     "CustomerPII",
+		ID:             customer.ID,
+		LegalName:      customer.LegalName,
+		DocumentIDHash: customer.DocumentIDHash,
+		Contact:        customer.Contact,
+		Salt:           salt,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	public := Customer{
+		ObjectType: customer.ObjectType,
+		ID:         customer.ID,
+		MSPID:      customer.MSPID,
+		ClientID:   customer.ClientID,
+		PIIHash:    piiHash,
+	}
+	customerAsBytes, err := json.Marshal(public)
+	if err != nil {
+		return nil, errors.New("Cannot marshal customer: " + err.Error())
+	}
+	err = stub.PutState("CUS"+customer.ID, customerAsBytes)
+	if err != nil {
+		return nil, errors.New("Failed to put state of customer " + customer.ID + ": " + err.Error())
+	}
+	return customerAsBytes, nil
+}
+
+// hashDocumentID - Returns the hex SHA-256 hash of an identity document ID
+func hashDocumentID(documentID string) string {
+	hash := sha256.Sum256([]byte(documentID))
+	return hex.EncodeToString(hash[:])
+}
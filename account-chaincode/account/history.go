@@ -0,0 +1,222 @@
+package account
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/hyperledger-fabric-go-chaincodes/query"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/ledger/queryresult"
+	"github.com/hyperledger/fabric/protos/peer"
+)
+
+// HistoryEntry structure with a version of an account, as returned by
+// GetHistory, plus the timestamp of the transaction that wrote it
+type HistoryEntry struct {
+	TxID      string          `json:"TxID"`
+	Timestamp string          `json:"Timestamp"`
+	Value     json.RawMessage `json:"Value"`
+	IsDeleted bool            `json:"IsDeleted"`
+}
+
+// BalanceAt structure returned by GetBalanceAt. The balance of a confidential
+// account is not in its public history, so only its commitment is returned
+type BalanceAt struct {
+	AccountNumber     int    `json:"accountNumber"`
+	At                string `json:"at"`
+	AccountBalance    *int   `json:"accountBalance,omitempty"`
+	HeldBalance       int    `json:"heldBalance"`
+	BalanceCommitment string `json:"balanceCommitment,omitempty"`
+	TxID              string `json:"txId"`
+	Timestamp         string `json:"timestamp"`
+}
+
+// GetBalanceAt - Queries the balance of an account at an instant, from the
+// version of the account in effect then: the last one written at or before it
+// params: AccountNumber, Instant (RFC 3339, e.g. 2019-03-31T23:59:59Z)
+func GetBalanceAt(stub shim.ChaincodeStubInterface, logger *shim.ChaincodeLogger, args []string) peer.Response {
+	logger.Info("Entry method: GetBalanceAt")
+	logger.Debug("Received args:", args)
+
+	// Input sanitation
+	if len(args) != 2 {
+		logger.Info("Exit method: GetBalanceAt")
+		return shim.Error("Incorrect number of arguments. 2 expected")
+	}
+	_, err := strconv.Atoi(args[0])
+	if err != nil {
+		logger.Info("Exit method: GetBalanceAt")
+		return shim.Error("Account number must be a numeric string")
+	}
+	at, err := time.Parse(time.RFC3339, args[1])
+	if err != nil {
+		logger.Info("Exit method: GetBalanceAt")
+		return shim.Error("Instant must be an RFC 3339 timestamp: " + err.Error())
+	}
+
+	resultsIterator, err := stub.GetHistoryForKey("ACC" + args[0])
+	if err != nil {
+		logger.Info("Exit method: GetBalanceAt")
+		return shim.Error("Failed to fetch account history: " + err.Error())
+	}
+	defer resultsIterator.Close()
+
+	// The order of the history is not relied upon: the version in effect is the
+	// latest one not after the instant
+	var inEffect *queryresult.KeyModification
+	var inEffectTime time.Time
+	for resultsIterator.HasNext() {
+		modification, err := resultsIterator.Next()
+		if err != nil {
+			logger.Info("Exit method: GetBalanceAt")
+			return shim.Error("Failed to iterate over account history: " + err.Error())
+		}
+		modificationTime, err := historyTime(modification)
+		if err != nil {
+			logger.Info("Exit method: GetBalanceAt")
+			return shim.Error(err.Error())
+		}
+		if modificationTime.After(at) {
+			continue
+		}
+		if inEffect == nil || !modificationTime.Before(inEffectTime) {
+			inEffect, inEffectTime = modification, modificationTime
+		}
+	}
+	if inEffect == nil || inEffect.IsDelete || inEffect.Value == nil {
+		logger.Info("Exit method: GetBalanceAt")
+		return shim.Error("Account ACC" + args[0] + " did not exist at " + args[1])
+	}
+
+	account := &Account{}
+	err = json.Unmarshal(inEffect.Value, account)
+	if err != nil {
+		logger.Info("Exit method: GetBalanceAt")
+		return shim.Error("Cannot unmarshal account: " + err.Error())
+	}
+	balanceAt := BalanceAt{
+		AccountNumber:     account.AccountNumber,
+		At:                at.UTC().Format(time.RFC3339Nano),
+		HeldBalance:       account.HeldBalance,
+		BalanceCommitment: account.BalanceCommitment,
+		TxID:              inEffect.TxId,
+		Timestamp:         inEffectTime.Format(time.RFC3339Nano),
+	}
+	if !account.Confidential() {
+		balanceAt.AccountBalance = &account.AccountBalance
+	}
+
+	balanceAtAsBytes, err := json.Marshal(balanceAt)
+	if err != nil {
+		logger.Info("Exit method: GetBalanceAt")
+		return shim.Error("Cannot marshal balance: " + err.Error())
+	}
+
+	logger.Info("Exit method: GetBalanceAt")
+	return shim.Success(balanceAtAsBytes)
+}
+
+// GetHistoryBetween - Get the history of an account, as GetHistory does, limited
+// to the versions written between two instants, both included. An empty instant
+// leaves the range open. At most query.DefaultMaxRecords versions are returned
+// params: AccountNumber, From, To (RFC 3339)
+func GetHistoryBetween(stub shim.ChaincodeStubInterface, logger *shim.ChaincodeLogger, args []string) peer.Response {
+	logger.Info("Entry method: GetHistoryBetween")
+	logger.Debug("Received args:", args)
+
+	// Input sanitation
+	if len(args) != 3 {
+		logger.Info("Exit method: GetHistoryBetween")
+		return shim.Error("Incorrect number of arguments. 3 expected")
+	}
+	_, err := strconv.Atoi(args[0])
+	if err != nil {
+		logger.Info("Exit method: GetHistoryBetween")
+		return shim.Error("Account number must be a numeric string")
+	}
+	from, err := parseInstant(args[1])
+	if err != nil {
+		logger.Info("Exit method: GetHistoryBetween")
+		return shim.Error(err.Error())
+	}
+	to, err := parseInstant(args[2])
+	if err != nil {
+		logger.Info("Exit method: GetHistoryBetween")
+		return shim.Error(err.Error())
+	}
+	if !from.IsZero() && !to.IsZero() && to.Before(from) {
+		logger.Info("Exit method: GetHistoryBetween")
+		return shim.Error("Start of the range must not be after its end")
+	}
+
+	resultsIterator, err := stub.GetHistoryForKey("ACC" + args[0])
+	if err != nil {
+		logger.Info("Exit method: GetHistoryBetween")
+		return shim.Error("Failed to fetch account history: " + err.Error())
+	}
+	defer resultsIterator.Close()
+
+	entries := []HistoryEntry{}
+	for resultsIterator.HasNext() {
+		modification, err := resultsIterator.Next()
+		if err != nil {
+			logger.Info("Exit method: GetHistoryBetween")
+			return shim.Error("Failed to iterate over account history: " + err.Error())
+		}
+		modificationTime, err := historyTime(modification)
+		if err != nil {
+			logger.Info("Exit method: GetHistoryBetween")
+			return shim.Error(err.Error())
+		}
+		if (!from.IsZero() && modificationTime.Before(from)) || (!to.IsZero() && modificationTime.After(to)) {
+			continue
+		}
+		if len(entries) == query.DefaultMaxRecords {
+			logger.Info("Exit method: GetHistoryBetween")
+			return shim.Error("History of ACC" + args[0] + " has more than " + strconv.Itoa(query.DefaultMaxRecords) + " versions in the range, narrow it")
+		}
+
+		entry := HistoryEntry{
+			TxID:      modification.TxId,
+			Timestamp: modificationTime.Format(time.RFC3339Nano),
+			Value:     modification.Value,
+			IsDeleted: modification.IsDelete || modification.Value == nil,
+		}
+		// Check if account has been deleted
+		if entry.IsDeleted {
+			entry.Value = json.RawMessage("{}")
+		}
+		entries = append(entries, entry)
+	}
+
+	entriesAsBytes, err := json.Marshal(entries)
+	if err != nil {
+		logger.Info("Exit method: GetHistoryBetween")
+		return shim.Error("Cannot marshal account history: " + err.Error())
+	}
+
+	logger.Info("Exit method: GetHistoryBetween")
+	return shim.Success(entriesAsBytes)
+}
+
+// historyTime - Returns the timestamp of the transaction behind a version of a key
+func historyTime(modification *queryresult.KeyModification) (time.Time, error) {
+	if modification.Timestamp == nil {
+		return time.Time{}, errors.New("History entry of transaction " + modification.TxId + " has no timestamp")
+	}
+	return time.Unix(modification.Timestamp.Seconds, int64(modification.Timestamp.Nanos)).UTC(), nil
+}
+
+// parseInstant - Parses an RFC 3339 instant. An empty one is the zero time
+func parseInstant(instant string) (time.Time, error) {
+	if instant == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, instant)
+	if err != nil {
+		return time.Time{}, errors.New("Instant must be an RFC 3339 timestamp: " + err.Error())
+	}
+	return t, nil
+}
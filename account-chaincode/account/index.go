@@ -0,0 +1,377 @@
+package account
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+
+	"github.com/hyperledger-fabric-go-chaincodes/auth"
+	"github.com/hyperledger-fabric-go-chaincodes/query"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/peer"
+)
+
+// Composite key indexes, so that owner lookups work on LevelDB too. Names are
+// PII, so their indexes are kept in PIICollection
+const (
+	customerAccountIndex = "customer~account"
+	ownerAccountIndex    = "owner~account"
+	nameCustomerIndex    = "name~customer"
+)
+
+// RebuildIndexes - Rebuilds the owner indexes of every account and customer,
+// e.g. for data written before the indexes existed. Accounts of a customer
+// written before joint accounts existed get it as their primary owner. Given a
+// piiSalt, it also moves the owner names still in public account records into
+// PIICollection. Restricted to admins
+// transient: "piiSalt", optional secret of at least 16 bytes salting the owner names
+func RebuildIndexes(stub shim.ChaincodeStubInterface, logger *shim.ChaincodeLogger) peer.Response {
+	logger.Info("Entry method: RebuildIndexes")
+
+	err := auth.Require(stub, auth.Admin)
+	if err != nil {
+		logger.Info("Exit method: RebuildIndexes")
+		return shim.Error(err.Error())
+	}
+
+	salt, err := getTransientInput(stub, "piiSalt")
+	if err != nil {
+		logger.Info("Exit method: RebuildIndexes")
+		return shim.Error(err.Error())
+	}
+
+	accountsIterator, err := stub.GetStateByRange("ACC", "ACD")
+	if err != nil {
+		logger.Info("Exit method: RebuildIndexes")
+		return shim.Error("Cannot get ledger state: " + err.Error())
+	}
+	defer accountsIterator.Close()
+
+	accounts, migrated := 0, 0
+	for accountsIterator.HasNext() {
+		result, err := accountsIterator.Next()
+		if err != nil {
+			logger.Info("Exit method: RebuildIndexes")
+			return shim.Error("Cannot iterate accounts: " + err.Error())
+		}
+		account := &Account{}
+		err = json.Unmarshal(result.Value, account)
+		if err != nil {
+			logger.Info("Exit method: RebuildIndexes")
+			return shim.Error("Cannot unmarshal account " + result.Key + ": " + err.Error())
+		}
+		if account.PIIHash != "" {
+			pii := AccountPII{}
+			_, err = getPII(stub, result.Key, &pii)
+			if err != nil {
+				logger.Info("Exit method: RebuildIndexes")
+				return shim.Error(err.Error())
+			}
+			account.AccountOwner = pii.AccountOwner
+		}
+		// Accounts created before joint accounts get their customer as owner,
+		// so that customerAccountsQuery finds them
+		withoutOwners := account.CustomerID != "" && len(account.Owners) == 0
+		if withoutOwners {
+			account.Owners = []Owner{{CustomerID: account.CustomerID, Role: RolePrimary}}
+		}
+		if withoutOwners || (account.PIIHash == "" && account.AccountOwner != "" && salt != "") {
+			// Rewriting a legacy account moves its owner name into PIICollection
+			err = loadBalance(stub, account)
+			if err == nil {
+				_, err = putAccount(stub, account)
+			}
+			migrated++
+		} else {
+			err = indexAccount(stub, nil, account)
+		}
+		if err != nil {
+			logger.Info("Exit method: RebuildIndexes")
+			return shim.Error(err.Error())
+		}
+		accounts++
+	}
+
+	customersIterator, err := stub.GetStateByRange("CUS", "CUT")
+	if err != nil {
+		logger.Info("Exit method: RebuildIndexes")
+		return shim.Error("Cannot get ledger state: " + err.Error())
+	}
+	defer customersIterator.Close()
+
+	customers := 0
+	for customersIterator.HasNext() {
+		result, err := customersIterator.Next()
+		if err != nil {
+			logger.Info("Exit method: RebuildIndexes")
+			return shim.Error("Cannot iterate customers: " + err.Error())
+		}
+		pii := CustomerPII{}
+		found, err := getPII(stub, result.Key, &pii)
+		if err != nil {
+			logger.Info("Exit method: RebuildIndexes")
+			return shim.Error(err.Error())
+		}
+		if !found {
+			continue
+		}
+		err = indexCustomer(stub, pii.ID, "", pii.LegalName)
+		if err != nil {
+			logger.Info("Exit method: RebuildIndexes")
+			return shim.Error(err.Error())
+		}
+		customers++
+	}
+
+	logger.Info("Exit method: RebuildIndexes")
+	return shim.Success([]byte("{\"accounts\":" + strconv.Itoa(accounts) + ",\"migrated\":" + strconv.Itoa(migrated) + ",\"customers\":" + strconv.Itoa(customers) + "}"))
+}
+
+// customersNamed - Returns the IDs of the customers with a legal name
+func customersNamed(stub shim.ChaincodeStubInterface, name string) ([]string, error) {
+	customerResults, err := query.GetPrivateQueryResult(stub, PIICollection, customersNamedQuery(name))
+	if query.Unsupported(err) {
+		return query.GetIndexedKeys(stub, PIICollection, nameCustomerIndex, name)
+	} else if err != nil {
+		return nil, errors.New("Cannot get query results: " + err.Error())
+	}
+
+	var customers struct {
+		Records []struct {
+			Record CustomerPII
+		}
+	}
+	err = json.Unmarshal(customerResults, &customers)
+	if err != nil {
+		return nil, errors.New("Cannot unmarshal customers: " + err.Error())
+	}
+	customerIDs := []string{}
+	for _, customer := range customers.Records {
+		customerIDs = append(customerIDs, customer.Record.ID)
+	}
+	return customerIDs, nil
+}
+
+// accountsOwnedBy - Returns the numbers of the accounts with a free-text owner name
+func accountsOwnedBy(stub shim.ChaincodeStubInterface, name string) ([]int, error) {
+	accountResults, err := query.GetPrivateQueryResult(stub, PIICollection, accountsOwnedByQuery(name))
+	if query.Unsupported(err) {
+		keys, err := query.GetIndexedKeys(stub, PIICollection, ownerAccountIndex, name)
+		if err != nil {
+			return nil, err
+		}
+		accNumbers := []int{}
+		for _, key := range keys {
+			accNumber, err := strconv.Atoi(key)
+			if err != nil {
+				return nil, errors.New("Invalid account number " + key + " in index " + ownerAccountIndex)
+			}
+			accNumbers = append(accNumbers, accNumber)
+		}
+		return accNumbers, nil
+	} else if err != nil {
+		return nil, errors.New("Cannot get query results: " + err.Error())
+	}
+
+	var ownerAccounts struct {
+		Records []struct {
+			Record AccountPII
+		}
+	}
+	err = json.Unmarshal(accountResults, &ownerAccounts)
+	if err != nil {
+		return nil, errors.New("Cannot unmarshal accounts: " + err.Error())
+	}
+	accNumbers := []int{}
+	for _, ownerAccount := range ownerAccounts.Records {
+		accNumbers = append(accNumbers, ownerAccount.Record.AccountNumber)
+	}
+	return accNumbers, nil
+}
+
+// heldAccounts - Returns as query results the given accounts and the accounts
+// held by the given customers, found with customerAccountsQuery or, on state
+// databases without rich queries, by walking the customer~account index
+func heldAccounts(stub shim.ChaincodeStubInterface, accNumbers []int, customerIDs []string) ([]byte, error) {
+	keys := []string{}
+	seen := make(map[string]bool)
+	add := func(key string) {
+		if !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+
+	for _, accNumber := range accNumbers {
+		add("ACC" + strconv.Itoa(accNumber))
+	}
+	if len(customerIDs) == 0 {
+		return query.ConstructQueryResponseFromKeys(stub, keys)
+	}
+
+	customerKeys, err := query.GetQueryKeys(stub, customerAccountsQuery(customerIDs))
+	if query.Unsupported(err) {
+		customerKeys, err = indexedAccountKeys(stub, customerIDs)
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range customerKeys {
+		add(key)
+	}
+	return query.ConstructQueryResponseFromKeys(stub, keys)
+}
+
+// indexedAccountKeys - Returns the keys of the accounts of the given customers,
+// walking the customer~account index
+func indexedAccountKeys(stub shim.ChaincodeStubInterface, customerIDs []string) ([]string, error) {
+	keys := []string{}
+	for _, customerID := range customerIDs {
+		customerAccounts, err := query.GetIndexedKeys(stub, "", customerAccountIndex, customerID)
+		if err != nil {
+			return nil, err
+		}
+		for _, accNumber := range customerAccounts {
+			keys = append(keys, "ACC"+accNumber)
+		}
+	}
+	return keys, nil
+}
+
+// accountCustomers - Returns the IDs of the customers holding an account
+func accountCustomers(account *Account) []string {
+	customerIDs := []string{}
+	if account.CustomerID != "" {
+		customerIDs = append(customerIDs, account.CustomerID)
+	}
+	for _, owner := range account.Owners {
+		if !contains(customerIDs, owner.CustomerID) {
+			customerIDs = append(customerIDs, owner.CustomerID)
+		}
+	}
+	return customerIDs
+}
+
+// indexAccount - Updates the indexes of an account being written, given its
+// stored record (nil for a new account). The owner name index is only changed
+// when the account carries an owner name
+func indexAccount(stub shim.ChaincodeStubInterface, stored *Account, account *Account) error {
+	accNumber := strconv.Itoa(account.AccountNumber)
+
+	storedCustomers := []string{}
+	if stored != nil {
+		storedCustomers = accountCustomers(stored)
+	}
+	customers := accountCustomers(account)
+	for _, customerID := range storedCustomers {
+		if !contains(customers, customerID) {
+			err := delIndex(stub, "", customerAccountIndex, customerID, accNumber)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	for _, customerID := range customers {
+		err := putIndex(stub, "", customerAccountIndex, customerID, accNumber)
+		if err != nil {
+			return err
+		}
+	}
+
+	if account.AccountOwner == "" {
+		return nil
+	}
+	if stored != nil && stored.PIIHash != "" {
+		pii := AccountPII{}
+		_, err := getPII(stub, "ACC"+accNumber, &pii)
+		if err != nil {
+			return err
+		}
+		if pii.AccountOwner != "" && pii.AccountOwner != account.AccountOwner {
+			err = delIndex(stub, PIICollection, ownerAccountIndex, pii.AccountOwner, accNumber)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return putIndex(stub, PIICollection, ownerAccountIndex, account.AccountOwner, accNumber)
+}
+
+// unindexAccount - Removes an account being deleted from the indexes
+func unindexAccount(stub shim.ChaincodeStubInterface, account *Account) error {
+	accNumber := strconv.Itoa(account.AccountNumber)
+	for _, customerID := range accountCustomers(account) {
+		err := delIndex(stub, "", customerAccountIndex, customerID, accNumber)
+		if err != nil {
+			return err
+		}
+	}
+
+	if account.PIIHash == "" {
+		return nil
+	}
+	pii := AccountPII{}
+	_, err := getPII(stub, "ACC"+accNumber, &pii)
+	if err != nil || pii.AccountOwner == "" {
+		return err
+	}
+	return delIndex(stub, PIICollection, ownerAccountIndex, pii.AccountOwner, accNumber)
+}
+
+// indexCustomer - Moves a customer from its previous legal name (empty for a
+// new customer) to its legal name in the name index
+func indexCustomer(stub shim.ChaincodeStubInterface, customerID string, previousName string, name string) error {
+	if previousName != "" && previousName != name {
+		err := delIndex(stub, PIICollection, nameCustomerIndex, previousName, customerID)
+		if err != nil {
+			return err
+		}
+	}
+	return putIndex(stub, PIICollection, nameCustomerIndex, name, customerID)
+}
+
+// putIndex - Writes an index entry. Only the key is needed, so the value is a
+// nil byte. An empty collection writes to the public state
+func putIndex(stub shim.ChaincodeStubInterface, collection string, index string, attributes ...string) error {
+	indexKey, err := stub.CreateCompositeKey(index, attributes)
+	if err != nil {
+		return errors.New("Could not create " + index + " index key: " + err.Error())
+	}
+	if collection == "" {
+		err = stub.PutState(indexKey, []byte{0x00})
+	} else {
+		err = stub.PutPrivateData(collection, indexKey, []byte{0x00})
+	}
+	if err != nil {
+		return errors.New("Could not put " + index + " index entry: " + err.Error())
+	}
+	return nil
+}
+
+// delIndex - Deletes an index entry. An empty collection deletes from the
+// public state
+func delIndex(stub shim.ChaincodeStubInterface, collection string, index string, attributes ...string) error {
+	indexKey, err := stub.CreateCompositeKey(index, attributes)
+	if err != nil {
+		return errors.New("Could not create " + index + " index key: " + err.Error())
+	}
+	if collection == "" {
+		err = stub.DelState(indexKey)
+	} else {
+		err = stub.DelPrivateData(collection, indexKey)
+	}
+	if err != nil {
+		return errors.New("Could not delete " + index + " index entry: " + err.Error())
+	}
+	return nil
+}
+
+// contains - Tells whether a slice holds a string
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,352 @@
+package account
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hyperledger-fabric-go-chaincodes/auth"
+	"github.com/hyperledger-fabric-go-chaincodes/query"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/peer"
+)
+
+// interestDenominator - basis points per unit times seconds per (365 days) year
+const interestDenominator = 10000 * 365 * 24 * 60 * 60
+
+// JournalEntry structure with a posting on an account. It is stored as
+// JRN<accountNumber>-<date>, so the entries of an account sort by date. The
+// entries of confidential accounts are stored in PIICollection
+type JournalEntry struct {
+	ObjectType    string `json:"docType"`
+	TxID          string `json:"txId"`
+	AccountNumber int    `json:"accountNumber"`
+	Type          string `json:"type"`
+	Amount        int    `json:"amount"`
+	From          string `json:"from"`
+	To            string `json:"to"`
+	InterestRate  int    `json:"interestRate"`
+	Balance       int    `json:"balance"`
+}
+
+// SetInterestRate - Sets the yearly interest rate of an account in basis points.
+// Interest due at the previous rate is accrued first. Restricted to admins
+// params: AccountNumber, InterestRate
+func SetInterestRate(stub shim.ChaincodeStubInterface, logger *shim.ChaincodeLogger, args []string) peer.Response {
+	logger.Info("Entry method: SetInterestRate")
+	logger.Debug("Received args:", args)
+
+	err := auth.Require(stub, auth.Admin)
+	if err != nil {
+		logger.Info("Exit method: SetInterestRate")
+		return shim.Error(err.Error())
+	}
+
+	// Input sanitation
+	if len(args) != 2 {
+		logger.Info("Exit method: SetInterestRate")
+		return shim.Error("Incorrect number of arguments. 2 expected")
+	}
+	rate, err := strconv.Atoi(args[1])
+	if err != nil || rate < 0 {
+		logger.Info("Exit method: SetInterestRate")
+		return shim.Error("Interest rate must be a non-negative numeric string")
+	}
+
+	account, err := getAccount(stub, args[0])
+	if err != nil {
+		logger.Info("Exit method: SetInterestRate")
+		return shim.Error(err.Error())
+	}
+	now, err := accrualTime(stub)
+	if err != nil {
+		logger.Info("Exit method: SetInterestRate")
+		return shim.Error(err.Error())
+	}
+	_, err = accrue(stub, account, now)
+	if err != nil {
+		logger.Info("Exit method: SetInterestRate")
+		return shim.Error(err.Error())
+	}
+	account.InterestRate = rate
+
+	accountAsBytes, err := putAccount(stub, account)
+	if err != nil {
+		logger.Info("Exit method: SetInterestRate")
+		return shim.Error(err.Error())
+	}
+
+	err = stub.SetEvent("interest_rate_updated", accountAsBytes)
+	if err != nil {
+		logger.Critical("Failed to set event `interest_rate_updated`: " + err.Error())
+		logger.Info("Exit method: SetInterestRate")
+		return shim.Error("Failed to set event `interest_rate_updated`: " + err.Error())
+	}
+
+	logger.Info("Exit method: SetInterestRate")
+	return shim.Success(accountAsBytes)
+}
+
+// AccrueInterest - Credits the interest earned since the last accrual on the
+// positive balance of the given accounts (all accounts when none is given) and
+// posts it as journal entries. The fraction left by integer division is carried
+// to the next accrual, and running it again at the same instant accrues nothing.
+// Restricted to operators
+// params: [AccountNumber...]
+func AccrueInterest(stub shim.ChaincodeStubInterface, logger *shim.ChaincodeLogger, args []string) peer.Response {
+	logger.Info("Entry method: AccrueInterest")
+	logger.Debug("Received args:", args)
+
+	err := auth.Require(stub, auth.Operator, auth.Admin)
+	if err != nil {
+		logger.Info("Exit method: AccrueInterest")
+		return shim.Error(err.Error())
+	}
+
+	// Get the accounts to accrue
+	var accounts []*Account
+	if len(args) == 0 {
+		accountsIterator, err := stub.GetStateByRange("ACC", "ACD")
+		if err != nil {
+			logger.Info("Exit method: AccrueInterest")
+			return shim.Error("Cannot get ledger state: " + err.Error())
+		}
+		defer accountsIterator.Close()
+
+		for accountsIterator.HasNext() {
+			result, err := accountsIterator.Next()
+			if err != nil {
+				logger.Info("Exit method: AccrueInterest")
+				return shim.Error("Cannot iterate accounts: " + err.Error())
+			}
+			account := &Account{}
+			err = json.Unmarshal(result.Value, account)
+			if err != nil {
+				logger.Info("Exit method: AccrueInterest")
+				return shim.Error("Cannot unmarshal account " + result.Key + ": " + err.Error())
+			}
+			err = loadBalance(stub, account)
+			if err != nil {
+				logger.Info("Exit method: AccrueInterest")
+				return shim.Error(err.Error())
+			}
+			accounts = append(accounts, account)
+		}
+	}
+	for _, accNumber := range args {
+		account, err := getAccount(stub, accNumber)
+		if err != nil {
+			logger.Info("Exit method: AccrueInterest")
+			return shim.Error(err.Error())
+		}
+		accounts = append(accounts, account)
+	}
+
+	now, err := accrualTime(stub)
+	if err != nil {
+		logger.Info("Exit method: AccrueInterest")
+		return shim.Error(err.Error())
+	}
+
+	entries := []*JournalEntry{}
+	for _, account := range accounts {
+		if account.InterestRate == 0 && account.LastAccrual == "" {
+			continue
+		}
+		entry, err := accrue(stub, account, now)
+		if err != nil {
+			logger.Info("Exit method: AccrueInterest")
+			return shim.Error(err.Error())
+		}
+		_, err = putAccount(stub, account)
+		if err != nil {
+			logger.Info("Exit method: AccrueInterest")
+			return shim.Error(err.Error())
+		}
+		// Entries of confidential accounts would reveal their balance
+		if entry != nil && !account.Confidential() {
+			entries = append(entries, entry)
+		}
+	}
+
+	entriesAsBytes, err := json.Marshal(entries)
+	if err != nil {
+		logger.Info("Exit method: AccrueInterest")
+		return shim.Error("Cannot marshal journal entries: " + err.Error())
+	}
+
+	err = stub.SetEvent("interest_accrued", entriesAsBytes)
+	if err != nil {
+		logger.Critical("Failed to set event `interest_accrued`: " + err.Error())
+		logger.Info("Exit method: AccrueInterest")
+		return shim.Error("Failed to set event `interest_accrued`: " + err.Error())
+	}
+
+	logger.Info("Exit method: AccrueInterest")
+	return shim.Success(entriesAsBytes)
+}
+
+// GetJournal - Queries the journal entries of an account, oldest first. A
+// truncated response is continued by passing back its bookmark
+// params: AccountNumber, [Bookmark]
+func GetJournal(stub shim.ChaincodeStubInterface, logger *shim.ChaincodeLogger, args []string) peer.Response {
+	logger.Info("Entry method: GetJournal")
+	logger.Debug("Received args:", args)
+
+	// Input sanitation
+	if len(args) != 1 && len(args) != 2 {
+		logger.Info("Exit method: GetJournal")
+		return shim.Error("Incorrect number of arguments. 1 or 2 expected")
+	}
+	if _, err := strconv.Atoi(args[0]); err != nil {
+		logger.Info("Exit method: GetJournal")
+		return shim.Error("Account number must be numeric string")
+	}
+	startKey := "JRN" + args[0] + "-"
+	if len(args) == 2 && args[1] != "" {
+		if !strings.HasPrefix(args[1], startKey) {
+			logger.Info("Exit method: GetJournal")
+			return shim.Error("Bookmark is not a journal entry of ACC" + args[0])
+		}
+		startKey = args[1]
+	}
+
+	account, err := readAccount(stub, args[0])
+	if err != nil {
+		logger.Info("Exit method: GetJournal")
+		return shim.Error(err.Error())
+	}
+
+	// "." sorts right after "-", bounding the range to the entries of this account
+	var entriesIterator shim.StateQueryIteratorInterface
+	if account.Confidential() {
+		if !piiAuthorized(stub) {
+			logger.Info("Exit method: GetJournal")
+			return shim.Error("Organization not authorized to read the journal of ACC" + args[0])
+		}
+		entriesIterator, err = stub.GetPrivateDataByRange(PIICollection, startKey, "JRN"+args[0]+".")
+	} else {
+		entriesIterator, err = stub.GetStateByRange(startKey, "JRN"+args[0]+".")
+	}
+	if err != nil {
+		logger.Info("Exit method: GetJournal")
+		return shim.Error("Cannot get ledger state: " + err.Error())
+	}
+	defer entriesIterator.Close()
+
+	queryResults, err := query.ConstructQueryResponseFromIterator(entriesIterator)
+	if err != nil {
+		logger.Info("Exit method: GetJournal")
+		return shim.Error("Failed to construct results from iterator: " + err.Error())
+	}
+
+	logger.Info("Exit method: GetJournal")
+	return shim.Success(queryResults)
+}
+
+// accrue - Credits the interest of an account since its last accrual and posts
+// the journal entry. The first accrual only starts the period. Returns nil when
+// no interest was credited. The caller writes the account back
+func accrue(stub shim.ChaincodeStubInterface, account *Account, now time.Time) (*JournalEntry, error) {
+	to := now.Format(time.RFC3339)
+	if account.LastAccrual == "" {
+		account.LastAccrual = to
+		return nil, nil
+	}
+	from, err := time.Parse(time.RFC3339, account.LastAccrual)
+	if err != nil {
+		return nil, errors.New("Cannot parse last accrual of account ACC" + strconv.Itoa(account.AccountNumber) + ": " + err.Error())
+	}
+	elapsed := int64(now.Sub(from) / time.Second)
+	if elapsed <= 0 {
+		return nil, nil
+	}
+	account.LastAccrual = to
+
+	// Only positive balances earn interest
+	if account.AccountBalance <= 0 || account.InterestRate == 0 {
+		account.InterestCarry = 0
+		return nil, nil
+	}
+
+	// interest = balance * rate * elapsed / denominator, with big integers so the
+	// product cannot overflow, and the remainder carried to the next accrual
+	numerator := big.NewInt(int64(account.AccountBalance))
+	numerator.Mul(numerator, big.NewInt(int64(account.InterestRate)))
+	numerator.Mul(numerator, big.NewInt(elapsed))
+	numerator.Add(numerator, big.NewInt(account.InterestCarry))
+	interest, carry := new(big.Int).QuoRem(numerator, big.NewInt(interestDenominator), new(big.Int))
+	account.InterestCarry = carry.Int64()
+	if interest.Sign() == 0 {
+		return nil, nil
+	}
+	if !interest.IsInt64() {
+		return nil, errors.New("Interest of account ACC" + strconv.Itoa(account.AccountNumber) + " overflows")
+	}
+	account.AccountBalance += int(interest.Int64())
+
+	entry := &JournalEntry{
+		ObjectType:    "JournalEntry",
+		TxID:          stub.GetTxID(),
+		AccountNumber: account.AccountNumber,
+		Type:          "interest",
+		Amount:        int(interest.Int64()),
+		From:          from.Format(time.RFC3339),
+		To:            to,
+		InterestRate:  account.InterestRate,
+		Balance:       account.AccountBalance,
+	}
+	entryAsBytes, err := json.Marshal(entry)
+	if err != nil {
+		return nil, errors.New("Cannot marshal journal entry: " + err.Error())
+	}
+	entryKey := "JRN" + strconv.Itoa(account.AccountNumber) + "-" + to
+	if account.Confidential() {
+		err = stub.PutPrivateData(PIICollection, entryKey, entryAsBytes)
+	} else {
+		err = stub.PutState(entryKey, entryAsBytes)
+	}
+	if err != nil {
+		return nil, errors.New("Failed to put state of journal entry: " + err.Error())
+	}
+	return entry, nil
+}
+
+// accrueBeforeChange - Accrues the interest earned by the stored balance of an
+// account before a write changes it, so that every period earns interest on the
+// balance it had. The interest is added to the balance being written
+func accrueBeforeChange(stub shim.ChaincodeStubInterface, account *Account) error {
+	stored, err := getAccount(stub, strconv.Itoa(account.AccountNumber))
+	if err != nil {
+		return err
+	}
+	if stored.AccountBalance == account.AccountBalance || (stored.InterestRate == 0 && stored.LastAccrual == "") {
+		return nil
+	}
+
+	now, err := accrualTime(stub)
+	if err != nil {
+		return err
+	}
+	balance := stored.AccountBalance
+	_, err = accrue(stub, stored, now)
+	if err != nil {
+		return err
+	}
+	account.AccountBalance += stored.AccountBalance - balance
+	account.LastAccrual = stored.LastAccrual
+	account.InterestCarry = stored.InterestCarry
+	return nil
+}
+
+// accrualTime - Returns the transaction timestamp, which is the same on every endorser
+func accrualTime(stub shim.ChaincodeStubInterface) (time.Time, error) {
+	timestamp, err := stub.GetTxTimestamp()
+	if err != nil {
+		return time.Time{}, errors.New("Cannot get transaction timestamp: " + err.Error())
+	}
+	return time.Unix(timestamp.Seconds, 0).UTC(), nil
+}
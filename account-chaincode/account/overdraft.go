@@ -0,0 +1,133 @@
+package account
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"github.com/hyperledger-fabric-go-chaincodes/auth"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/peer"
+)
+
+// Funds structure reporting what an account can spend. Available is the
+// balance not held in escrow plus the remaining overdraft
+type Funds struct {
+	AccountNumber      int `json:"accountNumber"`
+	AccountBalance     int `json:"accountBalance"`
+	HeldBalance        int `json:"heldBalance"`
+	OverdraftLimit     int `json:"overdraftLimit"`
+	OverdraftRemaining int `json:"overdraftRemaining"`
+	Available          int `json:"available"`
+}
+
+// SetOverdraftLimit - Sets the overdraft limit of an account, i.e. how far its
+// balance may go negative, within the maximum of its product. Restricted to admins
+// params: AccountNumber, OverdraftLimit
+func SetOverdraftLimit(stub shim.ChaincodeStubInterface, logger *shim.ChaincodeLogger, args []string) peer.Response {
+	logger.Info("Entry method: SetOverdraftLimit")
+	logger.Debug("Received args:", args)
+
+	err := auth.Require(stub, auth.Admin)
+	if err != nil {
+		logger.Info("Exit method: SetOverdraftLimit")
+		return shim.Error(err.Error())
+	}
+
+	// Input sanitation
+	if len(args) != 2 {
+		logger.Info("Exit method: SetOverdraftLimit")
+		return shim.Error("Incorrect number of arguments. 2 expected")
+	}
+	limit, err := strconv.Atoi(args[1])
+	if err != nil || limit < 0 {
+		logger.Info("Exit method: SetOverdraftLimit")
+		return shim.Error("Overdraft limit must be a non-negative numeric string")
+	}
+
+	account, err := getAccount(stub, args[0])
+	if err != nil {
+		logger.Info("Exit method: SetOverdraftLimit")
+		return shim.Error(err.Error())
+	}
+	product, err := getProduct(stub, account.Product)
+	if err != nil {
+		logger.Info("Exit method: SetOverdraftLimit")
+		return shim.Error(err.Error())
+	}
+	if limit > 0 && !product.Allows(OpOverdraft) {
+		logger.Info("Exit method: SetOverdraftLimit")
+		return shim.Error("Product " + product.ID + " does not allow overdraft")
+	}
+	if limit > product.MaxOverdraft {
+		logger.Info("Exit method: SetOverdraftLimit")
+		return shim.Error("Overdraft limit of product " + product.ID + " is at most " + strconv.Itoa(product.MaxOverdraft))
+	}
+	if account.AccountBalance-account.HeldBalance+limit < 0 {
+		logger.Info("Exit method: SetOverdraftLimit")
+		return shim.Error("Account ACC" + args[0] + " is overdrawn beyond " + args[1])
+	}
+	account.OverdraftLimit = limit
+
+	accountAsBytes, err := putAccount(stub, account)
+	if err != nil {
+		logger.Info("Exit method: SetOverdraftLimit")
+		return shim.Error(err.Error())
+	}
+
+	err = stub.SetEvent("overdraft_limit_updated", accountAsBytes)
+	if err != nil {
+		logger.Critical("Failed to set event `overdraft_limit_updated`: " + err.Error())
+		logger.Info("Exit method: SetOverdraftLimit")
+		return shim.Error("Failed to set event `overdraft_limit_updated`: " + err.Error())
+	}
+
+	logger.Info("Exit method: SetOverdraftLimit")
+	return shim.Success(accountAsBytes)
+}
+
+// GetAvailableFunds - Queries the funds an account can spend
+// param: AccountNumber
+func GetAvailableFunds(stub shim.ChaincodeStubInterface, logger *shim.ChaincodeLogger, args []string) peer.Response {
+	logger.Info("Entry method: GetAvailableFunds")
+	logger.Debug("Received args:", args)
+
+	// Input sanitation
+	if len(args) != 1 {
+		logger.Info("Exit method: GetAvailableFunds")
+		return shim.Error("Incorrect number of arguments. 1 expected")
+	}
+
+	account, err := getAccount(stub, args[0])
+	if err != nil {
+		logger.Info("Exit method: GetAvailableFunds")
+		return shim.Error(err.Error())
+	}
+	if account.Confidential() && !balanceAuthorized(stub) {
+		logger.Info("Exit method: GetAvailableFunds")
+		return shim.Error("Organization not authorized to read the balance of ACC" + args[0])
+	}
+
+	funds := Funds{
+		AccountNumber:  account.AccountNumber,
+		AccountBalance: account.AccountBalance,
+		HeldBalance:    account.HeldBalance,
+		OverdraftLimit: account.OverdraftLimit,
+		Available:      account.AvailableBalance(),
+	}
+	funds.OverdraftRemaining = funds.OverdraftLimit
+	if funds.Available < funds.OverdraftRemaining {
+		funds.OverdraftRemaining = funds.Available
+	}
+	if funds.OverdraftRemaining < 0 {
+		funds.OverdraftRemaining = 0
+	}
+
+	fundsAsBytes, err := json.Marshal(funds)
+	if err != nil {
+		logger.Info("Exit method: GetAvailableFunds")
+		return shim.Error("Cannot marshal funds: " + err.Error())
+	}
+
+	logger.Info("Exit method: GetAvailableFunds")
+	return shim.Success(fundsAsBytes)
+}
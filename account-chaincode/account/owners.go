@@ -0,0 +1,305 @@
+package account
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+
+	"github.com/hyperledger-fabric-go-chaincodes/auth"
+	"github.com/hyperledger/fabric/core/chaincode/lib/cid"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/peer"
+)
+
+// Owner roles
+const (
+	RolePrimary = "primary"
+	RoleCoOwner = "co-owner"
+)
+
+// Signing rules: any owner may sign debits alone, or every owner must sign them
+const (
+	SigningAny = "any"
+	SigningAll = "all"
+)
+
+// Owner structure with a holder of an account
+type Owner struct {
+	CustomerID string `json:"customerId"`
+	Role       string `json:"role"`
+}
+
+// Signer structure answering who may sign debits of an account. Signers are
+// the owners with a Fabric identity, CustomerID is the owner matching the
+// caller identity, if any. Debits of accounts without signers are restricted to
+// operators
+type Signer struct {
+	AccountNumber int      `json:"accountNumber"`
+	SigningRule   string   `json:"signingRule"`
+	Signers       []string `json:"signers"`
+	CustomerID    string   `json:"customerId,omitempty"`
+}
+
+// AddCoOwner - Adds a co-owner to an account. Restricted to its primary owner and admins
+// params: AccountNumber, CustomerID
+func AddCoOwner(stub shim.ChaincodeStubInterface, logger *shim.ChaincodeLogger, args []string) peer.Response {
+	logger.Info("Entry method: AddCoOwner")
+	logger.Debug("Received args:", args)
+
+	// Input sanitation
+	if len(args) != 2 {
+		logger.Info("Exit method: AddCoOwner")
+		return shim.Error("Incorrect number of arguments. 2 expected")
+	}
+
+	account, err := getAccount(stub, args[0])
+	if err != nil {
+		logger.Info("Exit method: AddCoOwner")
+		return shim.Error(err.Error())
+	}
+	err = requirePrimaryOwner(stub, account)
+	if err != nil {
+		logger.Info("Exit method: AddCoOwner")
+		return shim.Error(err.Error())
+	}
+	customer, err := getCustomer(stub, args[1])
+	if err != nil {
+		logger.Info("Exit method: AddCoOwner")
+		return shim.Error(err.Error())
+	}
+	for _, owner := range account.Owners {
+		if owner.CustomerID == customer.ID {
+			logger.Info("Exit method: AddCoOwner")
+			return shim.Error("Customer " + customer.ID + " already owns account ACC" + args[0])
+		}
+	}
+	account.Owners = append(account.Owners, Owner{CustomerID: customer.ID, Role: RoleCoOwner})
+
+	response := putOwnersChange(stub, logger, account, "co_owner_added")
+	logger.Info("Exit method: AddCoOwner")
+	return response
+}
+
+// RemoveCoOwner - Removes a co-owner from an account. Restricted to its primary owner and admins
+// params: AccountNumber, CustomerID
+func RemoveCoOwner(stub shim.ChaincodeStubInterface, logger *shim.ChaincodeLogger, args []string) peer.Response {
+	logger.Info("Entry method: RemoveCoOwner")
+	logger.Debug("Received args:", args)
+
+	// Input sanitation
+	if len(args) != 2 {
+		logger.Info("Exit method: RemoveCoOwner")
+		return shim.Error("Incorrect number of arguments. 2 expected")
+	}
+
+	account, err := getAccount(stub, args[0])
+	if err != nil {
+		logger.Info("Exit method: RemoveCoOwner")
+		return shim.Error(err.Error())
+	}
+	err = requirePrimaryOwner(stub, account)
+	if err != nil {
+		logger.Info("Exit method: RemoveCoOwner")
+		return shim.Error(err.Error())
+	}
+
+	owners := []Owner{}
+	for _, owner := range account.Owners {
+		if owner.CustomerID == args[1] && owner.Role == RolePrimary {
+			logger.Info("Exit method: RemoveCoOwner")
+			return shim.Error("The primary owner of an account cannot be removed")
+		}
+		if owner.CustomerID != args[1] {
+			owners = append(owners, owner)
+		}
+	}
+	if len(owners) == len(account.Owners) {
+		logger.Info("Exit method: RemoveCoOwner")
+		return shim.Error("Customer " + args[1] + " does not own account ACC" + args[0])
+	}
+	account.Owners = owners
+
+	response := putOwnersChange(stub, logger, account, "co_owner_removed")
+	logger.Info("Exit method: RemoveCoOwner")
+	return response
+}
+
+// SetSigningRule - Sets whether any owner or every owner must sign the debits of
+// an account. Restricted to its primary owner and admins
+// params: AccountNumber, "any" or "all"
+func SetSigningRule(stub shim.ChaincodeStubInterface, logger *shim.ChaincodeLogger, args []string) peer.Response {
+	logger.Info("Entry method: SetSigningRule")
+	logger.Debug("Received args:", args)
+
+	// Input sanitation
+	if len(args) != 2 {
+		logger.Info("Exit method: SetSigningRule")
+		return shim.Error("Incorrect number of arguments. 2 expected")
+	}
+	if args[1] != SigningAny && args[1] != SigningAll {
+		logger.Info("Exit method: SetSigningRule")
+		return shim.Error("Signing rule must be \"" + SigningAny + "\" or \"" + SigningAll + "\"")
+	}
+
+	account, err := getAccount(stub, args[0])
+	if err != nil {
+		logger.Info("Exit method: SetSigningRule")
+		return shim.Error(err.Error())
+	}
+	err = requirePrimaryOwner(stub, account)
+	if err != nil {
+		logger.Info("Exit method: SetSigningRule")
+		return shim.Error(err.Error())
+	}
+	account.SigningRule = args[1]
+
+	response := putOwnersChange(stub, logger, account, "signing_rule_updated")
+	logger.Info("Exit method: SetSigningRule")
+	return response
+}
+
+// GetSigner - Queries who may sign the debits of an account and which of its
+// owners the caller is. Used by the transfer chaincode to authorize debits
+// param: AccountNumber
+func GetSigner(stub shim.ChaincodeStubInterface, logger *shim.ChaincodeLogger, args []string) peer.Response {
+	logger.Info("Entry method: GetSigner")
+	logger.Debug("Received args:", args)
+
+	// Input sanitation
+	if len(args) != 1 {
+		logger.Info("Exit method: GetSigner")
+		return shim.Error("Incorrect number of arguments. 1 expected")
+	}
+
+	account, err := getAccount(stub, args[0])
+	if err != nil {
+		logger.Info("Exit method: GetSigner")
+		return shim.Error(err.Error())
+	}
+	signer := Signer{AccountNumber: account.AccountNumber, SigningRule: account.SigningRule, Signers: []string{}}
+	if signer.SigningRule == "" {
+		signer.SigningRule = SigningAny
+	}
+
+	customers, err := ownerCustomers(stub, account)
+	if err != nil {
+		logger.Info("Exit method: GetSigner")
+		return shim.Error(err.Error())
+	}
+	for _, customer := range customers {
+		if customer.MSPID != "" && customer.ClientID != "" {
+			signer.Signers = append(signer.Signers, customer.ID)
+		}
+	}
+
+	// The caller identity is only read for accounts with signers
+	if len(signer.Signers) > 0 {
+		customer, err := callerCustomer(stub, customers)
+		if err != nil {
+			logger.Info("Exit method: GetSigner")
+			return shim.Error(err.Error())
+		}
+		if customer != nil {
+			signer.CustomerID = customer.ID
+		}
+	}
+
+	signerAsBytes, err := json.Marshal(signer)
+	if err != nil {
+		logger.Info("Exit method: GetSigner")
+		return shim.Error("Cannot marshal signer: " + err.Error())
+	}
+
+	logger.Info("Exit method: GetSigner")
+	return shim.Success(signerAsBytes)
+}
+
+// putOwnersChange - Writes an account after a change of its owners or signing
+// rule and notifies listeners
+func putOwnersChange(stub shim.ChaincodeStubInterface, logger *shim.ChaincodeLogger, account *Account, eventName string) peer.Response {
+	accountAsBytes, err := putAccount(stub, account)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	err = stub.SetEvent(eventName, accountAsBytes)
+	if err != nil {
+		logger.Critical("Failed to set event `" + eventName + "`: " + err.Error())
+		return shim.Error("Failed to set event `" + eventName + "`: " + err.Error())
+	}
+	return shim.Success(accountAsBytes)
+}
+
+// keepOwnership - Carries the stored customer, owners and signing rule of an
+// account over to a rewrite of it, which can only change them through
+// AddCoOwner, RemoveCoOwner and SetSigningRule. The account must exist
+func keepOwnership(stub shim.ChaincodeStubInterface, account *Account) error {
+	stored, err := readAccount(stub, strconv.Itoa(account.AccountNumber))
+	if err != nil {
+		return err
+	}
+	account.CustomerID = stored.CustomerID
+	account.Owners = stored.Owners
+	account.SigningRule = stored.SigningRule
+	return nil
+}
+
+// requirePrimaryOwner - Returns an error unless the caller is an admin or the
+// primary owner of the account
+func requirePrimaryOwner(stub shim.ChaincodeStubInterface, account *Account) error {
+	if auth.Require(stub, auth.Admin) == nil {
+		return nil
+	}
+
+	var primary []*Customer
+	for _, owner := range account.Owners {
+		if owner.Role == RolePrimary {
+			customer, err := getCustomer(stub, owner.CustomerID)
+			if err != nil {
+				return err
+			}
+			primary = append(primary, customer)
+		}
+	}
+	customer, err := callerCustomer(stub, primary)
+	if err != nil {
+		return err
+	}
+	if customer == nil {
+		return errors.New("Client identity is not the primary owner of account ACC" + strconv.Itoa(account.AccountNumber))
+	}
+	return nil
+}
+
+// ownerCustomers - Reads the customers owning an account
+func ownerCustomers(stub shim.ChaincodeStubInterface, account *Account) ([]*Customer, error) {
+	var customers []*Customer
+	for _, owner := range account.Owners {
+		customer, err := getCustomer(stub, owner.CustomerID)
+		if err != nil {
+			return nil, err
+		}
+		customers = append(customers, customer)
+	}
+	return customers, nil
+}
+
+// callerCustomer - Returns the customer whose Fabric identity is the caller
+// identity, or nil when none is
+func callerCustomer(stub shim.ChaincodeStubInterface, customers []*Customer) (*Customer, error) {
+	mspID, err := cid.GetMSPID(stub)
+	if err != nil {
+		return nil, errors.New("Cannot get client MSP ID: " + err.Error())
+	}
+	clientID, err := cid.GetID(stub)
+	if err != nil {
+		return nil, errors.New("Cannot get client identity: " + err.Error())
+	}
+
+	for _, customer := range customers {
+		if customer.MSPID == mspID && customer.ClientID == clientID {
+			return customer, nil
+		}
+	}
+	return nil, nil
+}
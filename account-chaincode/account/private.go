@@ -0,0 +1,122 @@
+package account
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strconv"
+
+	"github.com/hyperledger/fabric/core/chaincode/lib/cid"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// PIICollection is the private data collection holding owner names and
+// customer details. It must match collections_config.json
+const PIICollection = "collectionPII"
+
+// piiOrgs are the member organizations of PIICollection, the only ones allowed
+// to read its data. It must match the policy of collections_config.json
+var piiOrgs = []string{"Org1MSP"}
+
+// AccountPII structure with the private part of an account, stored in
+// PIICollection as ACC<accountNumber>
+type AccountPII struct {
+	ObjectType    string `json:"docType"`
+	AccountNumber int    `json:"accountNumber"`
+	AccountOwner  string `json:"accountOwner"`
+	Salt          string `json:"salt"`
+}
+
+// CustomerPII structure with the private part of a customer, stored in
+// PIICollection as CUS<id>
+type CustomerPII struct {
+	ObjectType     string   `json:"docType"`
+	ID             string   `json:"id"`
+	LegalName      string   `json:"legalName"`
+	DocumentIDHash string   `json:"documentIdHash"`
+	Contact        *Contact `json:"contact,omitempty"`
+	Salt           string   `json:"salt"`
+}
+
+// minPIISaltLength is the minimum length of the secret salting PII records
+const minPIISaltLength = 16
+
+// putPII - Writes private data into PIICollection and returns the hex SHA-256
+// hash of it, to be kept in the public record. The private data holds a salt
+// (see piiSalt) so that the hash cannot be matched against guessed values
+func putPII(stub shim.ChaincodeStubInterface, key string, pii interface{}) (string, error) {
+	piiAsBytes, err := json.Marshal(pii)
+	if err != nil {
+		return "", errors.New("Cannot marshal private data of " + key + ": " + err.Error())
+	}
+	err = stub.PutPrivateData(PIICollection, key, piiAsBytes)
+	if err != nil {
+		return "", errors.New("Failed to put private data of " + key + ": " + err.Error())
+	}
+	hash := sha256.Sum256(piiAsBytes)
+	return hex.EncodeToString(hash[:]), nil
+}
+
+// piiSalt - Returns the salt of a new version of the PII record of key: the
+// HMAC-SHA256 of the transaction ID and key by the secret passed in the
+// transient map as "piiSalt", so that endorsers agree on it and every record
+// gets its own, or else current, the salt of the stored version. Returns an
+// empty salt when there is neither
+func piiSalt(stub shim.ChaincodeStubInterface, key string, current string) (string, error) {
+	secret, err := getTransientInput(stub, "piiSalt")
+	if err != nil {
+		return "", err
+	}
+	if secret == "" {
+		return current, nil
+	}
+	if len(secret) < minPIISaltLength {
+		return "", errors.New("piiSalt must be at least " + strconv.Itoa(minPIISaltLength) + " bytes long")
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(stub.GetTxID() + ":" + key))
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// getPII - Reads private data of PIICollection into pii. Returns false when
+// there is none
+func getPII(stub shim.ChaincodeStubInterface, key string, pii interface{}) (bool, error) {
+	piiAsBytes, err := stub.GetPrivateData(PIICollection, key)
+	if err != nil {
+		return false, errors.New("Failed to fetch private data of " + key + ": " + err.Error())
+	} else if piiAsBytes == nil {
+		return false, nil
+	}
+	err = json.Unmarshal(piiAsBytes, pii)
+	if err != nil {
+		return false, errors.New("Cannot unmarshal private data of " + key + ": " + err.Error())
+	}
+	return true, nil
+}
+
+// piiAuthorized - Tells whether the caller belongs to an organization member of
+// PIICollection
+func piiAuthorized(stub shim.ChaincodeStubInterface) bool {
+	mspID, err := cid.GetMSPID(stub)
+	if err != nil {
+		return false
+	}
+	for _, org := range piiOrgs {
+		if mspID == org {
+			return true
+		}
+	}
+	return false
+}
+
+// getTransientInput - Reads an input passed in the transient map, so that it
+// is not recorded in the transaction. Returns an empty string when missing
+func getTransientInput(stub shim.ChaincodeStubInterface, key string) (string, error) {
+	transient, err := stub.GetTransient()
+	if err != nil {
+		return "", errors.New("Cannot get transient map: " + err.Error())
+	}
+	return string(transient[key]), nil
+}
@@ -0,0 +1,190 @@
+package account
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/hyperledger-fabric-go-chaincodes/auth"
+	"github.com/hyperledger-fabric-go-chaincodes/query"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/peer"
+)
+
+// Operations allowed by products
+const (
+	OpDebit     = "debit"
+	OpCredit    = "credit"
+	OpHold      = "hold"
+	OpOverdraft = "overdraft"
+)
+
+// DefaultProduct - product of accounts created without one
+const DefaultProduct = "checking"
+
+// Product structure of the account catalog. It is stored as PRD<id>. Tier
+// selects the transfer limits of its accounts, MaxOverdraft bounds their
+// overdraft limit, InterestRate is the yearly rate (basis points) of new
+// accounts and FeeExempt waives transfer fees paid by its accounts
+type Product struct {
+	ObjectType   string   `json:"docType"`
+	ID           string   `json:"id"`
+	Name         string   `json:"name"`
+	Operations   []string `json:"operations"`
+	Tier         string   `json:"tier"`
+	MaxOverdraft int      `json:"maxOverdraft"`
+	InterestRate int      `json:"interestRate"`
+	FeeExempt    bool     `json:"feeExempt"`
+}
+
+// defaultProducts - catalog written when the chaincode is instantiated or
+// upgraded, unless the products already exist
+var defaultProducts = []Product{
+	{ID: "checking", Name: "Checking account", Operations: []string{OpDebit, OpCredit, OpHold, OpOverdraft}, Tier: "standard", MaxOverdraft: 1000},
+	{ID: "savings", Name: "Savings account", Operations: []string{OpDebit, OpCredit}, Tier: "savings", InterestRate: 150},
+	{ID: "business", Name: "Business account", Operations: []string{OpDebit, OpCredit, OpHold, OpOverdraft}, Tier: "business", MaxOverdraft: 50000},
+	{ID: "merchant", Name: "Merchant account", Operations: []string{OpDebit, OpCredit, OpHold}, Tier: "merchant", FeeExempt: true},
+}
+
+// Allows - Tells whether the product allows an operation
+func (p *Product) Allows(operation string) bool {
+	for _, allowed := range p.Operations {
+		if allowed == operation {
+			return true
+		}
+	}
+	return false
+}
+
+// InitProducts - Writes the default products missing from the catalog
+// params: none
+func InitProducts(stub shim.ChaincodeStubInterface, logger *shim.ChaincodeLogger) error {
+	for i := range defaultProducts {
+		product := defaultProducts[i]
+		productAsBytes, err := stub.GetState("PRD" + product.ID)
+		if err != nil {
+			return errors.New("Failed to get state of product " + product.ID + ": " + err.Error())
+		} else if productAsBytes != nil {
+			continue
+		}
+
+		product.ObjectType = "Product"
+		_, err = putProduct(stub, &product)
+		if err != nil {
+			return err
+		}
+		logger.Debug("pushed PRD" + product.ID)
+	}
+	return nil
+}
+
+// SetProduct - Creates or replaces a product of the catalog. Restricted to admins
+// param: Product JSON
+func SetProduct(stub shim.ChaincodeStubInterface, logger *shim.ChaincodeLogger, args []string) peer.Response {
+	logger.Info("Entry method: SetProduct")
+	logger.Debug("Received args:", args)
+
+	err := auth.Require(stub, auth.Admin)
+	if err != nil {
+		logger.Info("Exit method: SetProduct")
+		return shim.Error(err.Error())
+	}
+
+	// Input sanitation
+	if len(args) != 1 {
+		logger.Info("Exit method: SetProduct")
+		return shim.Error("Incorrect number of arguments. 1 expected")
+	}
+	var product Product
+	err = json.Unmarshal([]byte(args[0]), &product)
+	if err != nil {
+		logger.Info("Exit method: SetProduct")
+		return shim.Error("Product not valid as json object: " + err.Error())
+	}
+	if product.ID == "" {
+		logger.Info("Exit method: SetProduct")
+		return shim.Error("Product id must be a non-empty string")
+	}
+	if product.MaxOverdraft < 0 || product.InterestRate < 0 {
+		logger.Info("Exit method: SetProduct")
+		return shim.Error("Product overdraft and interest rate must not be negative")
+	}
+	for _, operation := range product.Operations {
+		if operation != OpDebit && operation != OpCredit && operation != OpHold && operation != OpOverdraft {
+			logger.Info("Exit method: SetProduct")
+			return shim.Error("Product operation \"" + operation + "\" not recognized")
+		}
+	}
+
+	product.ObjectType = "Product"
+	productAsBytes, err := putProduct(stub, &product)
+	if err != nil {
+		logger.Info("Exit method: SetProduct")
+		return shim.Error(err.Error())
+	}
+
+	err = stub.SetEvent("product_updated", productAsBytes)
+	if err != nil {
+		logger.Critical("Failed to set event `product_updated`: " + err.Error())
+		logger.Info("Exit method: SetProduct")
+		return shim.Error("Failed to set event `product_updated`: " + err.Error())
+	}
+
+	logger.Info("Exit method: SetProduct")
+	return shim.Success(productAsBytes)
+}
+
+// GetProducts - Queries the product catalog
+// params: none
+func GetProducts(stub shim.ChaincodeStubInterface, logger *shim.ChaincodeLogger) peer.Response {
+	logger.Info("Entry method: GetProducts")
+
+	productsIterator, err := stub.GetStateByRange("PRD", "PRE")
+	if err != nil {
+		logger.Info("Exit method: GetProducts")
+		return shim.Error("Cannot get ledger state: " + err.Error())
+	}
+	defer productsIterator.Close()
+
+	queryResults, err := query.ConstructQueryResponseFromIterator(productsIterator)
+	if err != nil {
+		logger.Info("Exit method: GetProducts")
+		return shim.Error("Failed to construct results from iterator: " + err.Error())
+	}
+
+	logger.Info("Exit method: GetProducts")
+	return shim.Success(queryResults)
+}
+
+// getProduct - Reads a product of the catalog
+func getProduct(stub shim.ChaincodeStubInterface, id string) (*Product, error) {
+	if id == "" {
+		id = DefaultProduct
+	}
+
+	productAsBytes, err := stub.GetState("PRD" + id)
+	if err != nil {
+		return nil, errors.New("Failed to get state of product " + id + ": " + err.Error())
+	} else if productAsBytes == nil {
+		return nil, errors.New("Product " + id + " does not exist")
+	}
+
+	product := &Product{}
+	err = json.Unmarshal(productAsBytes, product)
+	if err != nil {
+		return nil, errors.New("Cannot unmarshal product " + id + ": " + err.Error())
+	}
+	return product, nil
+}
+
+// putProduct - Writes a product as PRD<id> and returns it as JSON
+func putProduct(stub shim.ChaincodeStubInterface, product *Product) ([]byte, error) {
+	productAsBytes, err := json.Marshal(product)
+	if err != nil {
+		return nil, errors.New("Cannot marshal product: " + err.Error())
+	}
+	err = stub.PutState("PRD"+product.ID, productAsBytes)
+	if err != nil {
+		return nil, errors.New("Failed to put state of product " + product.ID + ": " + err.Error())
+	}
+	return productAsBytes, nil
+}
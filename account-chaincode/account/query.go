@@ -0,0 +1,102 @@
+package account
+
+import (
+	"github.com/hyperledger-fabric-go-chaincodes/query"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/peer"
+)
+
+// queryAllowlist lists the fields of the public Account record that ad-hoc
+// queries may filter on, with their operators. PII is not in the public record
+// and confidential balances are zero there
+var queryAllowlist = query.Allowlist{
+	"accountNumber":  query.Comparison,
+	"accountBalance": query.Comparison,
+	"heldBalance":    query.Comparison,
+	"overdraftLimit": query.Comparison,
+	"interestRate":   query.Comparison,
+	"customerId":     {query.OpEq, query.OpNe, query.OpIn},
+	"product":        {query.OpEq, query.OpNe, query.OpIn},
+	"tier":           {query.OpEq, query.OpNe, query.OpIn},
+	"signingRule":    {query.OpEq, query.OpNe},
+}
+
+// Query - Runs an ad-hoc query on the accounts, restricted to the fields and
+// operators of queryAllowlist, and returns a page of results
+// param: ad-hoc query JSON, e.g. {"selector":{"accountBalance":{"$gt":10000},"customerId":"C1"},"limit":10,"bookmark":""}
+func Query(stub shim.ChaincodeStubInterface, logger *shim.ChaincodeLogger, args []string) peer.Response {
+	logger.Info("Entry method: Query")
+	logger.Debug("Received args:", args)
+
+	// Input sanitation
+	if len(args) != 1 {
+		logger.Info("Exit method: Query")
+		return shim.Error("Incorrect number of arguments. 1 expected")
+	}
+
+	q, pageSize, bookmark, err := adHocQuery(args[0])
+	if err != nil {
+		logger.Info("Exit method: Query")
+		return shim.Error(err.Error())
+	}
+
+	pageAsBytes, err := query.GetQueryResultWithPagination(stub, q, pageSize, bookmark)
+	if err != nil {
+		logger.Info("Exit method: Query")
+		return shim.Error("Cannot get query results: " + err.Error())
+	}
+
+	logger.Info("Exit method: Query")
+	return shim.Success(pageAsBytes)
+}
+
+// The rich queries of the chaincode. Each one names the index it runs on, which
+// must be shipped in META-INF/statedb/couchdb and be on fields its selector
+// filters on outside of $or alternatives, so that CouchDB can use it
+
+// accountIndexes lists the indexes of ad-hoc queries by the field they are on
+// besides docType, in order of preference. Queries filtering on none of these
+// fields run on accountDocIndex
+var accountIndexes = []query.Index{
+	{DDoc: "indexAccountCustomerDoc", Name: "indexAccountCustomer", Field: "customerId"},
+	{DDoc: "indexAccountBalanceDoc", Name: "indexAccountBalance", Field: "accountBalance"},
+}
+
+var accountDocIndex = query.Index{DDoc: "indexAccountDoc", Name: "indexAccount", Field: "docType"}
+
+// customerAccountsQuery - Selects the accounts held by any of the customers,
+// joint accounts included: owners lists every holder of an account. Values are
+// marshaled, so the IDs cannot alter the selector
+func customerAccountsQuery(customerIDs []string) *query.Query {
+	return query.NewQuery(query.NewSelector().
+		Eq("docType", "Account").
+		ElemMatch("owners", query.NewSelector().In("customerId", customerIDs))).
+		UseIndex("indexAccountOwnersDoc", "indexAccountOwners")
+}
+
+// customersNamedQuery - Selects the PII of the customers with a legal name, in PIICollection
+func customersNamedQuery(name string) *query.Query {
+	return query.NewQuery(query.NewSelector().
+		Eq("docType", "CustomerPII").
+		Eq("legalName", name)).
+		UseIndex("indexCustomerPIILegalNameDoc", "indexCustomerPIILegalName")
+}
+
+// accountsOwnedByQuery - Selects the PII of the accounts with a free-text owner
+// name, in PIICollection
+func accountsOwnedByQuery(name string) *query.Query {
+	return query.NewQuery(query.NewSelector().
+		Eq("docType", "AccountPII").
+		Eq("accountOwner", name)).
+		UseIndex("indexAccountPIIOwnerDoc", "indexAccountPIIOwner")
+}
+
+// adHocQuery - Parses an ad-hoc query on the accounts, see query.ParseAdHoc,
+// run on the index of the fields it filters on
+func adHocQuery(adHocAsString string) (*query.Query, int32, string, error) {
+	q, pageSize, bookmark, err := query.ParseAdHoc(adHocAsString, "Account", queryAllowlist)
+	if err != nil {
+		return nil, 0, "", err
+	}
+	return q.UseFirstIndex(accountIndexes, accountDocIndex), pageSize, bookmark, nil
+}
@@ -0,0 +1,37 @@
+/*
+Package auth provides authorization checks based on the attributes of the
+client identity certificate (e.g. registered in fabric-ca with
+`--id.attrs 'bank.operator=true:ecert'`).
+*/
+package auth
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/hyperledger/fabric/core/chaincode/lib/cid"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// Roles. A client identity has a role when its certificate holds the role
+// attribute with value "true"
+const (
+	Admin    = "bank.admin"
+	Operator = "bank.operator"
+	Approver = "bank.approver"
+	Arbiter  = "bank.arbiter"
+)
+
+// Require - Returns an error unless the client identity has at least one of the roles
+func Require(stub shim.ChaincodeStubInterface, roles ...string) error {
+	for _, role := range roles {
+		value, found, err := cid.GetAttributeValue(stub, role)
+		if err != nil {
+			return errors.New("cannot read client identity attributes: " + err.Error())
+		}
+		if found && value == "true" {
+			return nil
+		}
+	}
+	return errors.New("client identity is not authorized: " + strings.Join(roles, " or ") + " role required")
+}
@@ -0,0 +1,33 @@
+/*
+Package backend provides the ways off-chain clients reach the account, card and
+transfer chaincodes: a Fabric SDK implementation talking to the network and an
+in-memory implementation driving the chaincodes through MockStub.
+*/
+package backend
+
+// Chaincode names as installed on the network
+const (
+	AccountChaincode  = "cc-account"
+	CardChaincode     = "cc-card"
+	TransferChaincode = "cc-transfer"
+)
+
+// Backend submits transactions (Invoke) and evaluates read-only functions (Query).
+// InvokeTransient submits a transaction with private input in the transient
+// map, which is not recorded on the ledger
+type Backend interface {
+	Invoke(chaincode string, function string, args ...string) ([]byte, error)
+	InvokeTransient(chaincode string, function string, transient map[string][]byte, args ...string) ([]byte, error)
+	Query(chaincode string, function string, args ...string) ([]byte, error)
+}
+
+// ChaincodeError is returned when the chaincode itself rejected the call, as
+// opposed to the backend failing to reach it
+type ChaincodeError struct {
+	Status  int32
+	Message string
+}
+
+func (e *ChaincodeError) Error() string {
+	return e.Message
+}
@@ -0,0 +1,62 @@
+package backend
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/protos/msp"
+	"github.com/pkg/errors"
+)
+
+// attributesOID is the certificate extension where fabric-ca puts the
+// attributes of an identity, read by cid.GetAttributeValue
+var attributesOID = asn1.ObjectIdentifier{1, 2, 3, 4, 5, 6, 7, 8, 1}
+
+// newIdentity - Returns the serialized identity of a member of mspID, with a
+// self-signed certificate holding the given roles as fabric-ca attributes
+func newIdentity(mspID string, id string, roles ...string) ([]byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot generate identity key")
+	}
+
+	attrs := make(map[string]string)
+	for _, role := range roles {
+		attrs[role] = "true"
+	}
+	attrsAsBytes, err := json.Marshal(map[string]map[string]string{"attrs": attrs})
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot marshal identity attributes")
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:    big.NewInt(1),
+		Subject:         pkix.Name{CommonName: id, Organization: []string{mspID}},
+		NotBefore:       time.Now().Add(-time.Hour),
+		NotAfter:        time.Now().Add(10 * 365 * 24 * time.Hour),
+		KeyUsage:        x509.KeyUsageDigitalSignature,
+		ExtraExtensions: []pkix.Extension{{Id: attributesOID, Value: attrsAsBytes}},
+	}
+	certAsBytes, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot create identity certificate")
+	}
+
+	creator, err := proto.Marshal(&msp.SerializedIdentity{
+		Mspid:   mspID,
+		IdBytes: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certAsBytes}),
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot marshal identity")
+	}
+	return creator, nil
+}
@@ -0,0 +1,288 @@
+package backend
+
+import (
+	"strconv"
+	"sync"
+
+	"github.com/hyperledger-fabric-go-chaincodes/account-chaincode/account"
+	"github.com/hyperledger-fabric-go-chaincodes/auth"
+	"github.com/hyperledger-fabric-go-chaincodes/card-chaincode/card"
+	"github.com/hyperledger-fabric-go-chaincodes/transfer-chaincode/transfer"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/common"
+	"github.com/hyperledger/fabric/protos/peer"
+	"github.com/pkg/errors"
+)
+
+// Mock is an in-memory Backend running the three chaincodes on MockStubs wired
+// together for cross-chaincode invocations. MockStub has no rich query (CouchDB),
+// history nor private data deletion support, so functions relying on them fail,
+// except owner lookups which fall back to their composite key indexes.
+// Functions are called by a member of MockMSPID holding every role, unless
+// another identity is set. As on a peer, cross-chaincode invocations carry the
+// creator, the transient map and the proposal of the calling transaction
+type Mock struct {
+	mutex      sync.Mutex
+	stubs      map[string]*shim.MockStub
+	chaincodes map[string]shim.Chaincode
+	creator    []byte
+	txNum      int
+}
+
+// MockMSPID is the organization of the identities calling the Mock chaincodes
+const MockMSPID = "Org1MSP"
+
+// NewMock - Creates and initializes the chaincodes
+func NewMock() (*Mock, error) {
+	chaincodes := map[string]shim.Chaincode{
+		AccountChaincode:  new(account.AccountsChaincode),
+		CardChaincode:     new(card.CardChaincode),
+		TransferChaincode: new(transfer.TransferController),
+	}
+	accountStub := shim.NewMockStub(AccountChaincode, chaincodes[AccountChaincode])
+	cardStub := shim.NewMockStub(CardChaincode, chaincodes[CardChaincode])
+	transferStub := shim.NewMockStub(TransferChaincode, chaincodes[TransferChaincode])
+
+	cardStub.MockPeerChaincode(AccountChaincode, accountStub)
+	transferStub.MockPeerChaincode(AccountChaincode, accountStub)
+
+	m := &Mock{
+		stubs: map[string]*shim.MockStub{
+			AccountChaincode:  accountStub,
+			CardChaincode:     cardStub,
+			TransferChaincode: transferStub,
+		},
+		chaincodes: chaincodes,
+	}
+	for _, stub := range m.stubs {
+		stub.MockInit(m.nextTxID(), [][]byte{[]byte("INFO")})
+	}
+	if err := m.SetIdentity("admin", auth.Admin, auth.Operator, auth.Approver, auth.Arbiter); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// SetIdentity - Sets the identity of MockMSPID calling the chaincodes from then
+// on, holding the given roles
+func (m *Mock) SetIdentity(id string, roles ...string) error {
+	creator, err := newIdentity(MockMSPID, id, roles...)
+	if err != nil {
+		return err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.creator = creator
+	return nil
+}
+
+// Invoke - Runs a function as a transaction
+func (m *Mock) Invoke(chaincode string, function string, args ...string) ([]byte, error) {
+	return m.call(chaincode, function, args, nil)
+}
+
+// InvokeTransient - Runs a function as a transaction with a transient map
+func (m *Mock) InvokeTransient(chaincode string, function string, transient map[string][]byte, args ...string) ([]byte, error) {
+	return m.call(chaincode, function, args, transient)
+}
+
+// Query - Runs a function. MockStub does not distinguish queries from invokes
+func (m *Mock) Query(chaincode string, function string, args ...string) ([]byte, error) {
+	return m.call(chaincode, function, args, nil)
+}
+
+// call - Runs a function on the chaincode stub. MockStubs are not safe for
+// concurrent use, so calls are serialized. MockInvoke can pass neither a
+// creator, a transient map nor a proposal, so the chaincode is invoked through
+// an invocationStub instead
+func (m *Mock) call(chaincode string, function string, args []string, transient map[string][]byte) ([]byte, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	stub, ok := m.stubs[chaincode]
+	if !ok {
+		return nil, errors.Errorf("unknown chaincode `%s`", chaincode)
+	}
+
+	argsAsBytes := [][]byte{[]byte(function)}
+	for _, arg := range args {
+		argsAsBytes = append(argsAsBytes, []byte(arg))
+	}
+
+	txID := m.nextTxID()
+	proposal, err := newProposal(chaincode, txID, m.creator)
+	if err != nil {
+		return nil, err
+	}
+	stub.MockTransactionStart(txID)
+	response := m.chaincodes[chaincode].Invoke(&invocationStub{MockStub: stub, mock: m, args: argsAsBytes, creator: m.creator, transient: transient, proposal: proposal})
+	stub.MockTransactionEnd(txID)
+	if response.Status != shim.OK {
+		return nil, &ChaincodeError{Status: response.Status, Message: response.Message}
+	}
+	return response.Payload, nil
+}
+
+// newProposal - Returns a signed proposal of a transaction sent to a chaincode.
+// Chaincodes only read its header, so it is not actually signed
+func newProposal(chaincode string, txID string, creator []byte) (*peer.SignedProposal, error) {
+	extension, err := proto.Marshal(&peer.ChaincodeHeaderExtension{ChaincodeId: &peer.ChaincodeID{Name: chaincode}})
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot marshal proposal extension")
+	}
+	channelHeader, err := proto.Marshal(&common.ChannelHeader{
+		Type:      int32(common.HeaderType_ENDORSER_TRANSACTION),
+		TxId:      txID,
+		Extension: extension,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot marshal proposal channel header")
+	}
+	signatureHeader, err := proto.Marshal(&common.SignatureHeader{Creator: creator})
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot marshal proposal signature header")
+	}
+	header, err := proto.Marshal(&common.Header{ChannelHeader: channelHeader, SignatureHeader: signatureHeader})
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot marshal proposal header")
+	}
+	proposal, err := proto.Marshal(&peer.Proposal{Header: header})
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot marshal proposal")
+	}
+	return &peer.SignedProposal{ProposalBytes: proposal}, nil
+}
+
+// invocationStub is a MockStub with its own arguments, creator, transient map
+// and proposal, passed on to the chaincodes it invokes
+type invocationStub struct {
+	*shim.MockStub
+	mock      *Mock
+	args      [][]byte
+	creator   []byte
+	transient map[string][]byte
+	proposal  *peer.SignedProposal
+}
+
+func (s *invocationStub) GetArgs() [][]byte {
+	return s.args
+}
+
+func (s *invocationStub) GetStringArgs() []string {
+	strargs := make([]string, len(s.args))
+	for i, arg := range s.args {
+		strargs[i] = string(arg)
+	}
+	return strargs
+}
+
+func (s *invocationStub) GetFunctionAndParameters() (string, []string) {
+	strargs := s.GetStringArgs()
+	return strargs[0], strargs[1:]
+}
+
+func (s *invocationStub) GetCreator() ([]byte, error) {
+	return s.creator, nil
+}
+
+func (s *invocationStub) GetTransient() (map[string][]byte, error) {
+	return s.transient, nil
+}
+
+func (s *invocationStub) GetSignedProposal() (*peer.SignedProposal, error) {
+	return s.proposal, nil
+}
+
+// InvokeChaincode - Runs a function of another chaincode within the transaction,
+// as the same creator and with the same transient map and proposal. The Mock
+// is already locked by the calling transaction
+func (s *invocationStub) InvokeChaincode(chaincodeName string, args [][]byte, channel string) peer.Response {
+	stub, ok := s.mock.stubs[chaincodeName]
+	if !ok || channel != "" {
+		return shim.Error("unknown chaincode `" + chaincodeName + "` on channel `" + channel + "`")
+	}
+
+	stub.MockTransactionStart(s.TxID)
+	response := s.mock.chaincodes[chaincodeName].Invoke(&invocationStub{MockStub: stub, mock: s.mock, args: args, creator: s.creator, transient: s.transient, proposal: s.proposal})
+	stub.MockTransactionEnd(s.TxID)
+	return response
+}
+
+// nextTxID - Generates sequential transaction IDs
+func (m *Mock) nextTxID() string {
+	m.txNum++
+	return "mock-tx-" + strconv.Itoa(m.txNum)
+}
+
+// Snapshot structure with the world state and private data of every chaincode,
+// by chaincode name (and collection name for the private data)
+type Snapshot struct {
+	State   map[string]map[string][]byte            `json:"state"`
+	Private map[string]map[string]map[string][]byte `json:"private"`
+}
+
+// Snapshot - Returns a copy of the world state and private data of every chaincode
+func (m *Mock) Snapshot() *Snapshot {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	snapshot := &Snapshot{
+		State:   make(map[string]map[string][]byte),
+		Private: make(map[string]map[string]map[string][]byte),
+	}
+	for name, stub := range m.stubs {
+		snapshot.State[name] = make(map[string][]byte)
+		for key, value := range stub.State {
+			snapshot.State[name][key] = value
+		}
+		snapshot.Private[name] = make(map[string]map[string][]byte)
+		for collection, pvtState := range stub.PvtState {
+			snapshot.Private[name][collection] = make(map[string][]byte)
+			for key, value := range pvtState {
+				snapshot.Private[name][collection][key] = value
+			}
+		}
+	}
+	return snapshot
+}
+
+// Restore - Writes a snapshot back into the chaincodes world state and private data
+func (m *Mock) Restore(snapshot *Snapshot) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for name := range snapshot.State {
+		if _, ok := m.stubs[name]; !ok {
+			return errors.Errorf("unknown chaincode `%s`", name)
+		}
+	}
+	for name := range snapshot.Private {
+		if _, ok := m.stubs[name]; !ok {
+			return errors.Errorf("unknown chaincode `%s`", name)
+		}
+	}
+
+	for name, stub := range m.stubs {
+		txID := m.nextTxID()
+		stub.MockTransactionStart(txID)
+		for key, value := range snapshot.State[name] {
+			if err := stub.PutState(key, value); err != nil {
+				stub.MockTransactionEnd(txID)
+				return errors.Wrapf(err, "cannot restore `%s` key %s", name, key)
+			}
+		}
+		for collection, pvtState := range snapshot.Private[name] {
+			for key, value := range pvtState {
+				if err := stub.PutPrivateData(collection, key, value); err != nil {
+					stub.MockTransactionEnd(txID)
+					return errors.Wrapf(err, "cannot restore `%s` private key %s of %s", name, key, collection)
+				}
+			}
+		}
+		stub.MockTransactionEnd(txID)
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package backend
+
+import (
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/channel"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/errors/status"
+	"github.com/hyperledger/fabric-sdk-go/pkg/core/config"
+	"github.com/hyperledger/fabric-sdk-go/pkg/fabsdk"
+	"github.com/pkg/errors"
+)
+
+// SDK is the Backend submitting transactions to the network through the Fabric Go SDK
+type SDK struct {
+	sdk    *fabsdk.FabricSDK
+	client *channel.Client
+}
+
+// NewSDK - Creates a channel client for user from a connection profile
+// (e.g. basic-network/connection.yaml)
+func NewSDK(configPath string, channelID string, user string) (*SDK, error) {
+	sdk, err := fabsdk.New(config.FromFile(configPath))
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot create Fabric SDK")
+	}
+
+	client, err := channel.New(sdk.ChannelContext(channelID, fabsdk.WithUser(user)))
+	if err != nil {
+		sdk.Close()
+		return nil, errors.Wrap(err, "cannot create channel client")
+	}
+
+	return &SDK{sdk: sdk, client: client}, nil
+}
+
+// Close - Releases the SDK resources
+func (s *SDK) Close() {
+	s.sdk.Close()
+}
+
+// Invoke - Endorses, orders and waits for the commit of a transaction
+func (s *SDK) Invoke(chaincode string, function string, args ...string) ([]byte, error) {
+	response, err := s.client.Execute(request(chaincode, function, args))
+	if err != nil {
+		return nil, chaincodeError(err)
+	}
+	return response.Payload, nil
+}
+
+// InvokeTransient - Endorses, orders and waits for the commit of a transaction
+// with a transient map
+func (s *SDK) InvokeTransient(chaincode string, function string, transient map[string][]byte, args ...string) ([]byte, error) {
+	req := request(chaincode, function, args)
+	req.TransientMap = transient
+	response, err := s.client.Execute(req)
+	if err != nil {
+		return nil, chaincodeError(err)
+	}
+	return response.Payload, nil
+}
+
+// Query - Evaluates a function on the peer without submitting a transaction
+func (s *SDK) Query(chaincode string, function string, args ...string) ([]byte, error) {
+	response, err := s.client.Query(request(chaincode, function, args))
+	if err != nil {
+		return nil, chaincodeError(err)
+	}
+	return response.Payload, nil
+}
+
+// request - Builds a channel request from string arguments
+func request(chaincode string, function string, args []string) channel.Request {
+	argsAsBytes := make([][]byte, len(args))
+	for i, arg := range args {
+		argsAsBytes[i] = []byte(arg)
+	}
+	return channel.Request{ChaincodeID: chaincode, Fcn: function, Args: argsAsBytes}
+}
+
+// chaincodeError - Turns errors carrying a chaincode response status into ChaincodeError
+func chaincodeError(err error) error {
+	s, ok := status.FromError(err)
+	if ok && s.Group == status.ChaincodeStatus {
+		return &ChaincodeError{Status: s.Code, Message: s.Message}
+	}
+	return err
+}
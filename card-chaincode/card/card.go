@@ -4,6 +4,7 @@ Package card provides services in the context of card asset.
 package card
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"strconv"
@@ -20,6 +21,9 @@ type Card struct {
 	AccountNumber string `json:"accountNumber"`
 }
 
+// accountCardIndex - composite key index listing the cards of an account
+const accountCardIndex = "account~card"
+
 // Create - creates new card and stores into chaincode state
 // params: cardNumber, AccountNumber
 func Create(stub shim.ChaincodeStubInterface, args []string) peer.Response {
@@ -79,6 +83,21 @@ func Create(stub shim.ChaincodeStubInterface, args []string) peer.Response {
 		return shim.Error("Error: Could not put state of card: " + err.Error())
 	}
 
+	// Index card by account. Only the key is needed, so the value is a nil byte
+	accountCardKey, err := stub.CreateCompositeKey(accountCardIndex, []string{strconv.Itoa(accountNumber), cardNumberStr})
+	if err != nil {
+		return shim.Error("Error: Could not create account card index key: " + err.Error())
+	}
+	err = stub.PutState(accountCardKey, []byte{0x00})
+	if err != nil {
+		return shim.Error("Error: Could not put state of account card index: " + err.Error())
+	}
+
+	err = stub.SetEvent("card_created", cardJSONasBytes)
+	if err != nil {
+		return shim.Error("Error: Failed to set event `card_created`: " + err.Error())
+	}
+
 	// Card saved and indexed. Return success
 	fmt.Println("-- Ending card Create")
 	return shim.Success([]byte("Card created!"))
@@ -136,3 +155,57 @@ func GetAll(stub shim.ChaincodeStubInterface) peer.Response {
 	fmt.Println(records)
 	return shim.Success([]byte("Success"))
 }
+
+// GetByAccount - Get all cards related to an account
+// param: AccountNumber
+func GetByAccount(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	fmt.Println("-- Starting card GetByAccount")
+
+	// Input sanitation
+	if len(args) != 1 {
+		return shim.Error("Error: Incorrect number of arguments. 1 are expected!")
+	}
+	accountNumber, err := strconv.Atoi(args[0])
+	if err != nil {
+		return shim.Error("Error: 1st argument must be a numeric string")
+	}
+
+	// Walk the account card index
+	indexIterator, err := stub.GetStateByPartialCompositeKey(accountCardIndex, []string{strconv.Itoa(accountNumber)})
+	if err != nil {
+		return shim.Error("Error: Failed to query account card index: " + err.Error())
+	}
+	defer indexIterator.Close()
+
+	var b bytes.Buffer
+	b.WriteString("[")
+	for indexIterator.HasNext() {
+		indexEntry, err := indexIterator.Next()
+		if err != nil {
+			return shim.Error("Error while iterating through account card index: " + err.Error())
+		}
+		_, keyParts, err := stub.SplitCompositeKey(indexEntry.Key)
+		if err != nil {
+			return shim.Error("Error: Failed to split account card index key: " + err.Error())
+		}
+
+		cardAsJSON, err := stub.GetState("CARD" + keyParts[1])
+		if err != nil {
+			return shim.Error("Error: Failed to get state of card: " + keyParts[1])
+		} else if cardAsJSON == nil {
+			continue
+		}
+
+		// Add a comma before array members, suppress it for the first array member
+		if b.Len() > 1 {
+			b.WriteString(",")
+		}
+		b.WriteString("{\"Key\":\"CARD" + keyParts[1] + "\", \"Record\":")
+		b.Write(cardAsJSON)
+		b.WriteString("}")
+	}
+	b.WriteString("]")
+
+	fmt.Println("-- Ending card GetByAccount")
+	return shim.Success(b.Bytes())
+}
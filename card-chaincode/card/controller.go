@@ -0,0 +1,40 @@
+package card
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/peer"
+)
+
+// CardChaincode struct
+type CardChaincode struct {
+}
+
+// Init - initializes chaincode
+func (t *CardChaincode) Init(stub shim.ChaincodeStubInterface) peer.Response {
+	return shim.Success(nil)
+}
+
+// Invoke - Entry point for Invocations
+func (t *CardChaincode) Invoke(stub shim.ChaincodeStubInterface) peer.Response {
+	function, args := stub.GetFunctionAndParameters()
+	fmt.Println("[DEBUG] Card chaincode invoking " + function + " function")
+
+	// Handle different functions
+	switch function {
+	case "Create":
+		return Create(stub, args)
+	case "GetByNumber":
+		return GetByNumber(stub, args)
+	case "GetAll":
+		return GetAll(stub)
+	case "GetByAccount":
+		return GetByAccount(stub, args)
+	case "Query":
+		return Query(stub, args)
+	default:
+		// Error
+		return shim.Error("received unknown function invocation on card chaincode")
+	}
+}
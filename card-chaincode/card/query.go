@@ -0,0 +1,59 @@
+package card
+
+import (
+	"fmt"
+
+	"github.com/hyperledger-fabric-go-chaincodes/query"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/peer"
+)
+
+// queryAllowlist lists the fields of Card that ad-hoc queries may filter on,
+// with their operators. accountNumber is stored as a string
+var queryAllowlist = query.Allowlist{
+	"cardNumber":    query.Comparison,
+	"accountNumber": {query.OpEq, query.OpNe, query.OpIn},
+}
+
+// Query - Runs an ad-hoc query on the cards, restricted to the fields and
+// operators of queryAllowlist, and returns a page of results
+// param: ad-hoc query JSON, e.g. {"selector":{"accountNumber":{"$in":["1","2"]}},"limit":10,"bookmark":""}
+func Query(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	fmt.Println("-- Starting card Query")
+
+	// Input sanitation
+	if len(args) != 1 {
+		return shim.Error("Error: Incorrect number of arguments. 1 is expected!")
+	}
+
+	q, pageSize, bookmark, err := adHocQuery(args[0])
+	if err != nil {
+		return shim.Error("Error: " + err.Error())
+	}
+
+	pageAsBytes, err := query.GetQueryResultWithPagination(stub, q, pageSize, bookmark)
+	if err != nil {
+		return shim.Error("Error: Failed to get query results: " + err.Error())
+	}
+
+	fmt.Println("-- Ending card Query")
+	return shim.Success(pageAsBytes)
+}
+
+// cardIndexes lists the indexes of ad-hoc queries by the field they are on
+// besides docType. Queries filtering on none of these fields run on cardDocIndex
+var cardIndexes = []query.Index{
+	{DDoc: "indexCardAccountDoc", Name: "indexCardAccount", Field: "accountNumber"},
+}
+
+var cardDocIndex = query.Index{DDoc: "indexCardDoc", Name: "indexCard", Field: "docType"}
+
+// adHocQuery - Parses an ad-hoc query on the cards, see query.ParseAdHoc, run
+// on the index of the fields it filters on
+func adHocQuery(adHocAsString string) (*query.Query, int32, string, error) {
+	q, pageSize, bookmark, err := query.ParseAdHoc(adHocAsString, "Card", queryAllowlist)
+	if err != nil {
+		return nil, 0, "", err
+	}
+	return q.UseFirstIndex(cardIndexes, cardDocIndex), pageSize, bookmark, nil
+}
@@ -0,0 +1,171 @@
+package card
+
+import (
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger-fabric-go-chaincodes/query"
+)
+
+// couchDBIndex is an index definition of META-INF/statedb/couchdb
+type couchDBIndex struct {
+	Index struct {
+		Fields []string `json:"fields"`
+	} `json:"index"`
+	DDoc string `json:"ddoc"`
+	Name string `json:"name"`
+}
+
+// TestQueriesHaveIndexes checks that every rich query of the chaincode, i.e.
+// every function returning a *query.Query, runs on an index shipped with it and
+// that its selector filters on every field of that index, so that CouchDB can use it
+func TestQueriesHaveIndexes(t *testing.T) {
+	cases := map[string][]struct {
+		name    string
+		adHoc   string
+		index   string
+		invalid bool
+	}{
+		"adHocQuery": {
+			{"Query by account", `{"selector":{"accountNumber":{"$in":["1","2"]}},"limit":10}`, "indexCardAccount", false},
+			{"Query by account and card", `{"selector":{"accountNumber":"1","cardNumber":{"$gt":10}}}`, "indexCardAccount", false},
+			{"Query by card", `{"selector":{"cardNumber":{"$lte":10}}}`, "indexCard", false},
+			{"Query of every card", `{"selector":{}}`, "indexCard", false},
+			{"Query on a field not allowed", `{"selector":{"cvv":"123"}}`, "", true},
+		},
+	}
+
+	builders := queryBuilders(t, ".")
+	for builder := range builders {
+		if _, ok := cases[builder]; !ok {
+			t.Errorf("%s returns a *query.Query but has no case in TestQueriesHaveIndexes", builder)
+		}
+	}
+	for builder := range cases {
+		if !builders[builder] {
+			t.Errorf("%s has cases in TestQueriesHaveIndexes but does not return a *query.Query", builder)
+		}
+	}
+
+	for _, test := range cases["adHocQuery"] {
+		t.Run(test.name, func(t *testing.T) {
+			q, _, _, err := adHocQuery(test.adHoc)
+			if test.invalid {
+				if err == nil {
+					t.Errorf("query %s accepted, an error expected", test.adHoc)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("cannot parse query %s: %v", test.adHoc, err)
+			}
+			checkIndex(t, q, test.index)
+		})
+	}
+}
+
+// checkIndex - Checks that a query runs on the expected shipped index and
+// filters on every field of it
+func checkIndex(t *testing.T, q *query.Query, name string) {
+	queryString, err := q.Build()
+	if err != nil {
+		t.Fatalf("cannot build query: %v", err)
+	}
+	var parsed struct {
+		Selector map[string]json.RawMessage `json:"selector"`
+		UseIndex []string                   `json:"use_index"`
+	}
+	if err := json.Unmarshal([]byte(queryString), &parsed); err != nil {
+		t.Fatalf("cannot parse query %s: %v", queryString, err)
+	}
+	if len(parsed.UseIndex) != 2 || !strings.HasPrefix(parsed.UseIndex[0], "_design/") {
+		t.Fatalf("query %s does not name its index as [\"_design/<ddoc>\", <name>]", queryString)
+	}
+	if parsed.UseIndex[1] != name {
+		t.Errorf("query %s runs on index %s, %s expected", queryString, parsed.UseIndex[1], name)
+	}
+
+	index := findIndex(t, strings.TrimPrefix(parsed.UseIndex[0], "_design/"), parsed.UseIndex[1])
+	for _, field := range index.Index.Fields {
+		if _, ok := parsed.Selector[field]; !ok {
+			t.Errorf("selector of %s does not filter on %s, field of index %s", queryString, field, index.Name)
+		}
+	}
+}
+
+// queryBuilders - Returns the names of the functions of the package in dir,
+// tests left out, returning a *query.Query
+func queryBuilders(t *testing.T, dir string) map[string]bool {
+	notTest := func(info os.FileInfo) bool {
+		return !strings.HasSuffix(info.Name(), "_test.go")
+	}
+	packages, err := parser.ParseDir(token.NewFileSet(), dir, notTest, 0)
+	if err != nil {
+		t.Fatalf("cannot parse package in %s: %v", dir, err)
+	}
+
+	builders := make(map[string]bool)
+	for _, pkg := range packages {
+		for _, file := range pkg.Files {
+			for _, decl := range file.Decls {
+				function, ok := decl.(*ast.FuncDecl)
+				if !ok || function.Type.Results == nil {
+					continue
+				}
+				for _, result := range function.Type.Results.List {
+					if isQueryPointer(result.Type) {
+						builders[function.Name.Name] = true
+						break
+					}
+				}
+			}
+		}
+	}
+	return builders
+}
+
+// isQueryPointer - Tells whether a type expression is *query.Query
+func isQueryPointer(expr ast.Expr) bool {
+	star, ok := expr.(*ast.StarExpr)
+	if !ok {
+		return false
+	}
+	selector, ok := star.X.(*ast.SelectorExpr)
+	if !ok || selector.Sel.Name != "Query" {
+		return false
+	}
+	pkg, ok := selector.X.(*ast.Ident)
+	return ok && pkg.Name == "query"
+}
+
+// findIndex - Returns the shipped index with the given design document and name
+func findIndex(t *testing.T, ddoc string, name string) *couchDBIndex {
+	dir := filepath.Join("..", "META-INF", "statedb", "couchdb", "indexes")
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		t.Fatalf("cannot list indexes of %s: %v", dir, err)
+	}
+
+	for _, file := range files {
+		indexAsBytes, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatalf("cannot read index %s: %v", file, err)
+		}
+		index := &couchDBIndex{}
+		if err := json.Unmarshal(indexAsBytes, index); err != nil {
+			t.Fatalf("index %s not valid as json object: %v", file, err)
+		}
+		if index.DDoc == ddoc && index.Name == name {
+			return index
+		}
+	}
+	t.Fatalf("no index %s of design document %s in %s", name, ddoc, dir)
+	return nil
+}
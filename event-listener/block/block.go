@@ -0,0 +1,170 @@
+/*
+Package block decodes Fabric blocks into the transactions, chaincode events
+and state writes needed to project the ledger off-chain.
+*/
+package block
+
+import (
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-protos-go/common"
+	"github.com/hyperledger/fabric-protos-go/ledger/rwset"
+	"github.com/hyperledger/fabric-protos-go/ledger/rwset/kvrwset"
+	"github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/pkg/errors"
+)
+
+// Block structure with the decoded endorser transactions of a block
+type Block struct {
+	Number       uint64
+	Transactions []Transaction
+}
+
+// Transaction structure with the outcome of one endorser transaction.
+// Writes holds every namespace touched, including the ones written through
+// InvokeChaincode (e.g. cc-account writes performed by cc-transfer)
+type Transaction struct {
+	TxID      string
+	TxNumber  int
+	Timestamp time.Time
+	Valid     bool
+	Events    []Event
+	Writes    []Write
+}
+
+// Event structure with a chaincode event set by a transaction
+type Event struct {
+	ChaincodeID string
+	Name        string
+	Payload     []byte
+}
+
+// Write structure with a single key written (or deleted) by a transaction
+type Write struct {
+	Namespace string
+	Key       string
+	Value     []byte
+	IsDelete  bool
+}
+
+// Decode - Decodes a block, skipping config and other non endorser transactions
+func Decode(b *common.Block) (Block, error) {
+	decoded := Block{Number: b.GetHeader().GetNumber()}
+
+	// Validation codes are stored by the committer in the block metadata
+	var txFilter []byte
+	if metadata := b.GetMetadata().GetMetadata(); len(metadata) > int(common.BlockMetadataIndex_TRANSACTIONS_FILTER) {
+		txFilter = metadata[common.BlockMetadataIndex_TRANSACTIONS_FILTER]
+	}
+
+	for txNumber, envelopeAsBytes := range b.GetData().GetData() {
+		tx, isEndorserTx, err := decodeEnvelope(envelopeAsBytes)
+		if err != nil {
+			return Block{}, errors.Wrapf(err, "block %d, transaction %d", decoded.Number, txNumber)
+		}
+		if !isEndorserTx {
+			continue
+		}
+
+		tx.TxNumber = txNumber
+		tx.Valid = txNumber < len(txFilter) && peer.TxValidationCode(txFilter[txNumber]) == peer.TxValidationCode_VALID
+		decoded.Transactions = append(decoded.Transactions, tx)
+	}
+
+	return decoded, nil
+}
+
+// decodeEnvelope - Decodes one block data entry. The boolean result is false
+// for transactions that are not endorser transactions
+func decodeEnvelope(envelopeAsBytes []byte) (Transaction, bool, error) {
+	var tx Transaction
+
+	envelope := &common.Envelope{}
+	if err := proto.Unmarshal(envelopeAsBytes, envelope); err != nil {
+		return tx, false, errors.Wrap(err, "cannot unmarshal envelope")
+	}
+	payload := &common.Payload{}
+	if err := proto.Unmarshal(envelope.Payload, payload); err != nil {
+		return tx, false, errors.Wrap(err, "cannot unmarshal payload")
+	}
+	channelHeader := &common.ChannelHeader{}
+	if err := proto.Unmarshal(payload.GetHeader().GetChannelHeader(), channelHeader); err != nil {
+		return tx, false, errors.Wrap(err, "cannot unmarshal channel header")
+	}
+	if common.HeaderType(channelHeader.Type) != common.HeaderType_ENDORSER_TRANSACTION {
+		return tx, false, nil
+	}
+
+	tx.TxID = channelHeader.TxId
+	if ts := channelHeader.GetTimestamp(); ts != nil {
+		tx.Timestamp = time.Unix(ts.Seconds, int64(ts.Nanos)).UTC()
+	}
+
+	transaction := &peer.Transaction{}
+	if err := proto.Unmarshal(payload.Data, transaction); err != nil {
+		return tx, false, errors.Wrap(err, "cannot unmarshal transaction")
+	}
+
+	for _, action := range transaction.Actions {
+		chaincodeAction, err := decodeChaincodeAction(action)
+		if err != nil {
+			return tx, false, err
+		}
+
+		if len(chaincodeAction.Events) > 0 {
+			event := &peer.ChaincodeEvent{}
+			if err := proto.Unmarshal(chaincodeAction.Events, event); err != nil {
+				return tx, false, errors.Wrap(err, "cannot unmarshal chaincode event")
+			}
+			if event.EventName != "" {
+				tx.Events = append(tx.Events, Event{ChaincodeID: event.ChaincodeId, Name: event.EventName, Payload: event.Payload})
+			}
+		}
+
+		writes, err := decodeWrites(chaincodeAction.Results)
+		if err != nil {
+			return tx, false, err
+		}
+		tx.Writes = append(tx.Writes, writes...)
+	}
+
+	return tx, true, nil
+}
+
+// decodeChaincodeAction - Unwraps the chaincode action endorsed by the peers
+func decodeChaincodeAction(action *peer.TransactionAction) (*peer.ChaincodeAction, error) {
+	actionPayload := &peer.ChaincodeActionPayload{}
+	if err := proto.Unmarshal(action.Payload, actionPayload); err != nil {
+		return nil, errors.Wrap(err, "cannot unmarshal chaincode action payload")
+	}
+	responsePayload := &peer.ProposalResponsePayload{}
+	if err := proto.Unmarshal(actionPayload.GetAction().GetProposalResponsePayload(), responsePayload); err != nil {
+		return nil, errors.Wrap(err, "cannot unmarshal proposal response payload")
+	}
+	chaincodeAction := &peer.ChaincodeAction{}
+	if err := proto.Unmarshal(responsePayload.Extension, chaincodeAction); err != nil {
+		return nil, errors.Wrap(err, "cannot unmarshal chaincode action")
+	}
+	return chaincodeAction, nil
+}
+
+// decodeWrites - Extracts the public key writes of every namespace of a read-write set
+func decodeWrites(results []byte) ([]Write, error) {
+	txRWSet := &rwset.TxReadWriteSet{}
+	if err := proto.Unmarshal(results, txRWSet); err != nil {
+		return nil, errors.Wrap(err, "cannot unmarshal read-write set")
+	}
+
+	var writes []Write
+	for _, nsRWSet := range txRWSet.NsRwset {
+		kvRWSet := &kvrwset.KVRWSet{}
+		if err := proto.Unmarshal(nsRWSet.Rwset, kvRWSet); err != nil {
+			return nil, errors.Wrapf(err, "cannot unmarshal `%s` key-value set", nsRWSet.Namespace)
+		}
+		for _, kvWrite := range kvRWSet.Writes {
+			writes = append(writes, Write{Namespace: nsRWSet.Namespace, Key: kvWrite.Key, Value: kvWrite.Value, IsDelete: kvWrite.IsDelete})
+		}
+	}
+	return writes, nil
+}
@@ -0,0 +1,113 @@
+package block
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-protos-go/common"
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/event"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/providers/context"
+	"github.com/hyperledger/fabric-sdk-go/pkg/fab/events/deliverclient/seek"
+	"github.com/pkg/errors"
+)
+
+// FixtureExtension - file extension of recorded blocks
+const FixtureExtension = ".block"
+
+// Handler - callback receiving each block delivered by a Source
+type Handler func(b *common.Block) error
+
+// Source - delivers blocks, in order, starting from a given block number
+type Source interface {
+	Deliver(from uint64, handle Handler) error
+}
+
+// PeerSource delivers full blocks from a channel through the Fabric SDK event
+// client. Deliver blocks until the event channel is closed or handle fails
+type PeerSource struct {
+	Channel context.ChannelProvider
+}
+
+// Deliver - Registers for block events starting at block `from`
+func (s *PeerSource) Deliver(from uint64, handle Handler) error {
+	client, err := event.New(s.Channel, event.WithBlockEvents(), event.WithSeekType(seek.FromBlock), event.WithBlockNum(from))
+	if err != nil {
+		return errors.Wrap(err, "cannot create event client")
+	}
+
+	registration, blockEvents, err := client.RegisterBlockEvent()
+	if err != nil {
+		return errors.Wrap(err, "cannot register for block events")
+	}
+	defer client.Unregister(registration)
+
+	for blockEvent := range blockEvents {
+		if err := handle(blockEvent.Block); err != nil {
+			return err
+		}
+	}
+	return errors.New("block event channel closed")
+}
+
+// FixtureSource delivers blocks recorded as marshaled protobuf files in Dir
+// (see Recorder), which allows replaying a channel without a running network
+type FixtureSource struct {
+	Dir string
+}
+
+// Deliver - Reads every recorded block, in block number order, starting at block `from`
+func (s *FixtureSource) Deliver(from uint64, handle Handler) error {
+	paths, err := filepath.Glob(filepath.Join(s.Dir, "*"+FixtureExtension))
+	if err != nil {
+		return errors.Wrap(err, "cannot list block fixtures")
+	}
+
+	var blocks []*common.Block
+	for _, path := range paths {
+		blockAsBytes, err := ioutil.ReadFile(path)
+		if err != nil {
+			return errors.Wrap(err, "cannot read block fixture")
+		}
+		b := &common.Block{}
+		if err := proto.Unmarshal(blockAsBytes, b); err != nil {
+			return errors.Wrapf(err, "cannot unmarshal block fixture %s", path)
+		}
+		if b.GetHeader().GetNumber() >= from {
+			blocks = append(blocks, b)
+		}
+	}
+	sort.Slice(blocks, func(i, j int) bool {
+		return blocks[i].GetHeader().GetNumber() < blocks[j].GetHeader().GetNumber()
+	})
+
+	for _, b := range blocks {
+		if err := handle(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Recorder wraps a Handler and saves every block it receives into Dir, so the
+// files can later be replayed by a FixtureSource
+func Recorder(dir string, next Handler) (Handler, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, errors.Wrap(err, "cannot create fixture directory")
+	}
+
+	return func(b *common.Block) error {
+		blockAsBytes, err := proto.Marshal(b)
+		if err != nil {
+			return errors.Wrap(err, "cannot marshal block")
+		}
+		name := strconv.FormatUint(b.GetHeader().GetNumber(), 10) + FixtureExtension
+		if err := ioutil.WriteFile(filepath.Join(dir, name), blockAsBytes, 0644); err != nil {
+			return errors.Wrap(err, "cannot record block")
+		}
+		return next(b)
+	}, nil
+}
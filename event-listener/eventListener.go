@@ -0,0 +1,85 @@
+/*
+==== Run against the basic-network peer ====
+go run eventListener.go -config ../basic-network/connection.yaml -channel mychannel -db projection.db
+
+==== Record blocks while projecting, then replay them offline ====
+go run eventListener.go -record fixtures/
+go run eventListener.go -fixtures fixtures/ -db replay.db -from 0
+*/
+
+package main
+
+import (
+	"flag"
+	"log"
+
+	"github.com/hyperledger-fabric-go-chaincodes/event-listener/block"
+	"github.com/hyperledger-fabric-go-chaincodes/event-listener/projection"
+
+	"github.com/hyperledger/fabric-protos-go/common"
+	"github.com/hyperledger/fabric-sdk-go/pkg/core/config"
+	"github.com/hyperledger/fabric-sdk-go/pkg/fabsdk"
+)
+
+func main() {
+	configPath := flag.String("config", "../basic-network/connection.yaml", "Fabric SDK connection profile")
+	channel := flag.String("channel", "mychannel", "channel to listen to")
+	user := flag.String("user", "Admin", "identity used to receive full blocks")
+	dbPath := flag.String("db", "projection.db", "projection database file")
+	from := flag.Int64("from", -1, "replay from this block number (default: resume after the checkpoint)")
+	fixtures := flag.String("fixtures", "", "replay recorded blocks from this directory instead of the peer")
+	record := flag.String("record", "", "record every received block into this directory")
+	flag.Parse()
+
+	store, err := projection.Open(*dbPath)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	defer store.Close()
+
+	// Resume after the checkpoint unless a replay block was given. Records are
+	// versioned, so replaying blocks already applied is harmless
+	startBlock := uint64(0)
+	if *from >= 0 {
+		startBlock = uint64(*from)
+	} else if checkpoint, found, err := store.Checkpoint(); err != nil {
+		log.Fatalln(err)
+	} else if found {
+		startBlock = checkpoint + 1
+	}
+
+	var handler block.Handler = func(b *common.Block) error {
+		decoded, err := block.Decode(b)
+		if err != nil {
+			return err
+		}
+		if err := store.Apply(decoded); err != nil {
+			return err
+		}
+		log.Printf("applied block %d (%d transactions)", decoded.Number, len(decoded.Transactions))
+		return nil
+	}
+	if *record != "" {
+		handler, err = block.Recorder(*record, handler)
+		if err != nil {
+			log.Fatalln(err)
+		}
+	}
+
+	var source block.Source
+	if *fixtures != "" {
+		source = &block.FixtureSource{Dir: *fixtures}
+	} else {
+		sdk, err := fabsdk.New(config.FromFile(*configPath))
+		if err != nil {
+			log.Fatalln("cannot create Fabric SDK:", err)
+		}
+		defer sdk.Close()
+		source = &block.PeerSource{Channel: sdk.ChannelContext(*channel, fabsdk.WithUser(*user))}
+	}
+
+	log.Printf("projecting from block %d into %s", startBlock, *dbPath)
+	if err := source.Deliver(startBlock, handler); err != nil {
+		log.Fatalln(err)
+	}
+}
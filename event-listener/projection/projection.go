@@ -0,0 +1,271 @@
+/*
+Package projection maintains an off-chain projection of the account, card and
+transfer chaincodes in an embedded BoltDB database.
+*/
+package projection
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hyperledger-fabric-go-chaincodes/event-listener/block"
+	"github.com/pkg/errors"
+	bolt "go.etcd.io/bbolt"
+)
+
+// Bucket names of the projection
+const (
+	AccountsBucket  = "accounts"
+	CardsBucket     = "cards"
+	TransfersBucket = "transfers"
+	EventsBucket    = "events"
+	metaBucket      = "meta"
+	checkpointKey   = "checkpoint"
+)
+
+// stateCollection maps the world state keys of a chaincode into a bucket
+type stateCollection struct {
+	Bucket    string
+	Namespace string
+	KeyPrefix string
+}
+
+// eventCollection maps a chaincode event into a bucket keyed by transaction ID
+type eventCollection struct {
+	Bucket    string
+	Namespace string
+	EventName string
+}
+
+var stateCollections = []stateCollection{
+	{Bucket: AccountsBucket, Namespace: "cc-account", KeyPrefix: "ACC"},
+	{Bucket: CardsBucket, Namespace: "cc-card", KeyPrefix: "CARD"},
+}
+
+var eventCollections = []eventCollection{
+	{Bucket: TransfersBucket, Namespace: "cc-transfer", EventName: "money_transferred"},
+}
+
+// Version structure locating the transaction that produced a record. Records
+// are only overwritten by newer versions, so replaying blocks is idempotent
+type Version struct {
+	BlockNumber uint64 `json:"blockNumber"`
+	TxNumber    int    `json:"txNumber"`
+}
+
+// Record structure stored in every bucket. Deleted keys are kept as tombstones
+// so that a replay cannot resurrect them
+type Record struct {
+	Key       string          `json:"key"`
+	Value     json.RawMessage `json:"value,omitempty"`
+	Deleted   bool            `json:"deleted"`
+	TxID      string          `json:"txId"`
+	Timestamp time.Time       `json:"timestamp"`
+	Version   Version         `json:"version"`
+}
+
+// Store is the BoltDB backed projection
+type Store struct {
+	db *bolt.DB
+}
+
+// Open - Opens (or creates) the projection database at path
+func Open(path string) (*Store, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot open projection database")
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range []string{AccountsBucket, CardsBucket, TransfersBucket, EventsBucket, metaBucket} {
+			if _, err := tx.CreateBucketIfNotExists([]byte(name)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, errors.Wrap(err, "cannot create projection buckets")
+	}
+
+	return &Store{db: db}, nil
+}
+
+// Close - Closes the projection database
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Checkpoint - Returns the number of the last applied block. The boolean
+// result is false when no block has been applied yet
+func (s *Store) Checkpoint() (uint64, bool, error) {
+	var checkpoint uint64
+	var found bool
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		value := tx.Bucket([]byte(metaBucket)).Get([]byte(checkpointKey))
+		if value == nil {
+			return nil
+		}
+		number, err := strconv.ParseUint(string(value), 10, 64)
+		if err != nil {
+			return errors.Wrap(err, "corrupted checkpoint")
+		}
+		checkpoint, found = number, true
+		return nil
+	})
+	return checkpoint, found, err
+}
+
+// Apply - Projects the valid transactions of a block and moves the checkpoint
+// to it, all in a single database transaction
+func (s *Store) Apply(b block.Block) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		for _, transaction := range b.Transactions {
+			if !transaction.Valid {
+				continue
+			}
+			version := Version{BlockNumber: b.Number, TxNumber: transaction.TxNumber}
+
+			for _, write := range transaction.Writes {
+				for _, collection := range stateCollections {
+					if write.Namespace != collection.Namespace || !strings.HasPrefix(write.Key, collection.KeyPrefix) {
+						continue
+					}
+					record := Record{
+						Key:       strings.TrimPrefix(write.Key, collection.KeyPrefix),
+						Value:     asJSON(write.Value),
+						Deleted:   write.IsDelete,
+						TxID:      transaction.TxID,
+						Timestamp: transaction.Timestamp,
+						Version:   version,
+					}
+					if err := put(tx.Bucket([]byte(collection.Bucket)), record); err != nil {
+						return err
+					}
+				}
+			}
+
+			for i, event := range transaction.Events {
+				record := Record{
+					Key:       fmt.Sprintf("%020d-%06d-%03d", b.Number, transaction.TxNumber, i),
+					Value:     eventAsJSON(event),
+					TxID:      transaction.TxID,
+					Timestamp: transaction.Timestamp,
+					Version:   version,
+				}
+				if err := put(tx.Bucket([]byte(EventsBucket)), record); err != nil {
+					return err
+				}
+
+				for _, collection := range eventCollections {
+					if event.ChaincodeID != collection.Namespace || event.Name != collection.EventName {
+						continue
+					}
+					record.Key = transaction.TxID
+					record.Value = asJSON(event.Payload)
+					if err := put(tx.Bucket([]byte(collection.Bucket)), record); err != nil {
+						return err
+					}
+				}
+			}
+		}
+
+		return tx.Bucket([]byte(metaBucket)).Put([]byte(checkpointKey), []byte(strconv.FormatUint(b.Number, 10)))
+	})
+}
+
+// Get - Returns the record stored under key in bucket, or nil if there is none
+func (s *Store) Get(bucket string, key string) (*Record, error) {
+	var record *Record
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return errors.Errorf("unknown bucket `%s`", bucket)
+		}
+		value := b.Get([]byte(key))
+		if value == nil {
+			return nil
+		}
+		record = &Record{}
+		return json.Unmarshal(value, record)
+	})
+	return record, err
+}
+
+// List - Returns the live (non deleted) records of a bucket in key order
+func (s *Store) List(bucket string) ([]Record, error) {
+	var records []Record
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return errors.Errorf("unknown bucket `%s`", bucket)
+		}
+		return b.ForEach(func(_, value []byte) error {
+			var record Record
+			if err := json.Unmarshal(value, &record); err != nil {
+				return err
+			}
+			if !record.Deleted {
+				records = append(records, record)
+			}
+			return nil
+		})
+	})
+	return records, err
+}
+
+// put - Stores record unless the bucket already holds a newer version of it
+func put(b *bolt.Bucket, record Record) error {
+	if current := b.Get([]byte(record.Key)); current != nil {
+		var stored Record
+		if err := json.Unmarshal(current, &stored); err != nil {
+			return errors.Wrapf(err, "corrupted record `%s`", record.Key)
+		}
+		if newer(stored.Version, record.Version) {
+			return nil
+		}
+	}
+
+	recordAsBytes, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return b.Put([]byte(record.Key), recordAsBytes)
+}
+
+// newer - Reports whether version a was produced after version b
+func newer(a, b Version) bool {
+	if a.BlockNumber != b.BlockNumber {
+		return a.BlockNumber > b.BlockNumber
+	}
+	return a.TxNumber > b.TxNumber
+}
+
+// asJSON - Keeps valid JSON values as-is and stores anything else as a JSON string
+func asJSON(value []byte) json.RawMessage {
+	if len(value) == 0 {
+		return nil
+	}
+	if json.Valid(value) {
+		return json.RawMessage(value)
+	}
+	valueAsJSON, _ := json.Marshal(string(value))
+	return valueAsJSON
+}
+
+// eventAsJSON - Wraps a chaincode event with its origin
+func eventAsJSON(event block.Event) json.RawMessage {
+	eventAsBytes, _ := json.Marshal(struct {
+		ChaincodeID string          `json:"chaincodeId"`
+		Name        string          `json:"name"`
+		Payload     json.RawMessage `json:"payload,omitempty"`
+	}{event.ChaincodeID, event.Name, asJSON(event.Payload)})
+	return eventAsBytes
+}
@@ -0,0 +1,224 @@
+package projection
+
+import (
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/hyperledger-fabric-go-chaincodes/event-listener/block"
+	"github.com/hyperledger/fabric-protos-go/common"
+	"github.com/hyperledger/fabric-protos-go/ledger/rwset"
+	"github.com/hyperledger/fabric-protos-go/ledger/rwset/kvrwset"
+	"github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// fixtureTx is an endorser transaction of a block fixture
+type fixtureTx struct {
+	txID   string
+	valid  bool
+	writes map[string][]*kvrwset.KVWrite
+	event  *peer.ChaincodeEvent
+}
+
+// update rewrites the block fixtures of testdata from fixtureBlocks, with
+// go test -run TestReplayFixtures -update
+var update = flag.Bool("update", false, "rewrite the block fixtures of testdata/blocks")
+
+// fixturesDir holds the block fixtures, as block.Recorder writes them
+var fixturesDir = filepath.Join("testdata", "blocks")
+
+// TestReplayFixtures replays the blocks of account, card and transfer
+// transactions of testdata into a projection and checks it, then replays them
+// again to check that it does not change
+func TestReplayFixtures(t *testing.T) {
+	if *update {
+		record, err := block.Recorder(fixturesDir, func(*common.Block) error { return nil })
+		if err != nil {
+			t.Fatalf("cannot create recorder: %v", err)
+		}
+		for _, b := range fixtureBlocks(t) {
+			if err := record(b); err != nil {
+				t.Fatalf("cannot record block %d: %v", b.GetHeader().GetNumber(), err)
+			}
+		}
+	}
+
+	dir, err := ioutil.TempDir("", "projection")
+	if err != nil {
+		t.Fatalf("cannot create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := Open(filepath.Join(dir, "projection.db"))
+	if err != nil {
+		t.Fatalf("cannot open projection: %v", err)
+	}
+	defer store.Close()
+
+	for replay := 1; replay <= 2; replay++ {
+		source := &block.FixtureSource{Dir: fixturesDir}
+		err = source.Deliver(0, func(b *common.Block) error {
+			decoded, err := block.Decode(b)
+			if err != nil {
+				return err
+			}
+			return store.Apply(decoded)
+		})
+		if err != nil {
+			t.Fatalf("replay %d: cannot replay fixtures: %v", replay, err)
+		}
+		checkProjection(t, store, replay)
+	}
+}
+
+// checkProjection - Checks the projection of the fixtures
+func checkProjection(t *testing.T, store *Store, replay int) {
+	checkpoint, found, err := store.Checkpoint()
+	if err != nil || !found || checkpoint != 3 {
+		t.Errorf("replay %d: checkpoint is %d (found %t, error %v), 3 expected", replay, checkpoint, found, err)
+	}
+
+	// ACC1 was rewritten by the transfer, ACC2 deleted, ACC3 written by an invalid transaction
+	acc1, err := store.Get(AccountsBucket, "1")
+	if err != nil || acc1 == nil {
+		t.Fatalf("replay %d: account 1 not projected: %v", replay, err)
+	}
+	var account struct {
+		AccountBalance int `json:"accountBalance"`
+	}
+	if err := json.Unmarshal(acc1.Value, &account); err != nil || account.AccountBalance != 900 {
+		t.Errorf("replay %d: account 1 is %s, balance 900 expected", replay, acc1.Value)
+	}
+	if acc1.TxID != "tx-transfer" || acc1.Version != (Version{BlockNumber: 2, TxNumber: 0}) {
+		t.Errorf("replay %d: account 1 comes from %s at %+v, tx-transfer at block 2 expected", replay, acc1.TxID, acc1.Version)
+	}
+	acc2, err := store.Get(AccountsBucket, "2")
+	if err != nil || acc2 == nil || !acc2.Deleted {
+		t.Errorf("replay %d: account 2 is %+v (error %v), a tombstone expected", replay, acc2, err)
+	}
+	acc3, err := store.Get(AccountsBucket, "3")
+	if err != nil || acc3 != nil {
+		t.Errorf("replay %d: account 3 is %+v (error %v), none expected", replay, acc3, err)
+	}
+	accounts, err := store.List(AccountsBucket)
+	if err != nil || len(accounts) != 1 || accounts[0].Key != "1" {
+		t.Errorf("replay %d: live accounts are %+v (error %v), account 1 expected", replay, accounts, err)
+	}
+
+	card, err := store.Get(CardsBucket, "1")
+	if err != nil || card == nil || card.Deleted {
+		t.Errorf("replay %d: card 1 is %+v (error %v), a live card expected", replay, card, err)
+	}
+
+	transfer, err := store.Get(TransfersBucket, "tx-transfer")
+	if err != nil || transfer == nil {
+		t.Fatalf("replay %d: transfer not projected: %v", replay, err)
+	}
+	if string(transfer.Value) != `{"value":100}` {
+		t.Errorf("replay %d: transfer is %s, the event payload expected", replay, transfer.Value)
+	}
+	events, err := store.List(EventsBucket)
+	if err != nil || len(events) != 1 {
+		t.Errorf("replay %d: %d events projected (error %v), 1 expected", replay, len(events), err)
+	}
+}
+
+// fixtureBlocks - Returns the blocks recorded as fixtures by -update: accounts
+// created, one of them by an invalid transaction, and a card issued; a
+// transfer; an account deleted
+func fixtureBlocks(t *testing.T) []*common.Block {
+	return []*common.Block{
+		newBlock(t, 1, []fixtureTx{
+			{txID: "tx-accounts", valid: true, writes: map[string][]*kvrwset.KVWrite{
+				"cc-account": {
+					{Key: "ACC1", Value: []byte(`{"accountNumber":1,"accountBalance":1000}`)},
+					{Key: "ACC2", Value: []byte(`{"accountNumber":2,"accountBalance":1000}`)},
+				},
+			}},
+			{txID: "tx-conflict", valid: false, writes: map[string][]*kvrwset.KVWrite{
+				"cc-account": {{Key: "ACC3", Value: []byte(`{"accountNumber":3,"accountBalance":1000}`)}},
+			}},
+			{txID: "tx-card", valid: true, writes: map[string][]*kvrwset.KVWrite{
+				"cc-card": {{Key: "CARD1", Value: []byte(`{"cardNumber":1,"accountNumber":1}`)}},
+			}},
+		}),
+		newBlock(t, 2, []fixtureTx{
+			{txID: "tx-transfer", valid: true, writes: map[string][]*kvrwset.KVWrite{
+				"cc-account": {
+					{Key: "ACC1", Value: []byte(`{"accountNumber":1,"accountBalance":900}`)},
+					{Key: "ACC2", Value: []byte(`{"accountNumber":2,"accountBalance":1100}`)},
+				},
+			}, event: &peer.ChaincodeEvent{ChaincodeId: "cc-transfer", EventName: "money_transferred", Payload: []byte(`{"value":100}`)}},
+		}),
+		newBlock(t, 3, []fixtureTx{
+			{txID: "tx-delete", valid: true, writes: map[string][]*kvrwset.KVWrite{
+				"cc-account": {{Key: "ACC2", IsDelete: true}},
+			}},
+		}),
+	}
+}
+
+// newBlock - Builds a block of endorser transactions, with their validation codes
+func newBlock(t *testing.T, number uint64, txs []fixtureTx) *common.Block {
+	b := &common.Block{
+		Header:   &common.BlockHeader{Number: number},
+		Data:     &common.BlockData{},
+		Metadata: &common.BlockMetadata{Metadata: make([][]byte, common.BlockMetadataIndex_TRANSACTIONS_FILTER+1)},
+	}
+	txFilter := make([]byte, len(txs))
+	for i, tx := range txs {
+		b.Data.Data = append(b.Data.Data, newEnvelope(t, tx))
+		txFilter[i] = byte(peer.TxValidationCode_VALID)
+		if !tx.valid {
+			txFilter[i] = byte(peer.TxValidationCode_MVCC_READ_CONFLICT)
+		}
+	}
+	b.Metadata.Metadata[common.BlockMetadataIndex_TRANSACTIONS_FILTER] = txFilter
+	return b
+}
+
+// newEnvelope - Builds the envelope of an endorser transaction
+func newEnvelope(t *testing.T, tx fixtureTx) []byte {
+	txRWSet := &rwset.TxReadWriteSet{}
+	for namespace, writes := range tx.writes {
+		txRWSet.NsRwset = append(txRWSet.NsRwset, &rwset.NsReadWriteSet{
+			Namespace: namespace,
+			Rwset:     marshal(t, &kvrwset.KVRWSet{Writes: writes}),
+		})
+	}
+	chaincodeAction := &peer.ChaincodeAction{Results: marshal(t, txRWSet)}
+	if tx.event != nil {
+		chaincodeAction.Events = marshal(t, tx.event)
+	}
+
+	actionPayload := &peer.ChaincodeActionPayload{Action: &peer.ChaincodeEndorsedAction{
+		ProposalResponsePayload: marshal(t, &peer.ProposalResponsePayload{Extension: marshal(t, chaincodeAction)}),
+	}}
+	transaction := &peer.Transaction{Actions: []*peer.TransactionAction{{Payload: marshal(t, actionPayload)}}}
+
+	channelHeader := &common.ChannelHeader{
+		Type:      int32(common.HeaderType_ENDORSER_TRANSACTION),
+		TxId:      tx.txID,
+		Timestamp: &timestamp.Timestamp{Seconds: time.Date(2019, 3, 1, 0, 0, 0, 0, time.UTC).Unix()},
+	}
+	payload := &common.Payload{
+		Header: &common.Header{ChannelHeader: marshal(t, channelHeader)},
+		Data:   marshal(t, transaction),
+	}
+	return marshal(t, &common.Envelope{Payload: marshal(t, payload)})
+}
+
+// marshal - Marshals a protobuf message of a fixture
+func marshal(t *testing.T, message proto.Message) []byte {
+	messageAsBytes, err := proto.Marshal(message)
+	if err != nil {
+		t.Fatalf("cannot marshal fixture message: %v", err)
+	}
+	return messageAsBytes
+}
@@ -0,0 +1,355 @@
+/*
+Package cli implements the fabricbank commands: it builds the chaincode
+arguments from command-line flags, calls a backend and pretty-prints the result.
+*/
+package cli
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/hyperledger-fabric-go-chaincodes/account-chaincode/account"
+	"github.com/hyperledger-fabric-go-chaincodes/backend"
+	"github.com/pkg/errors"
+)
+
+// Usage - Command summary printed on invalid usage
+const Usage = `Commands:
+  customer create --id ID --legal-name NAME --document-id DOC [--email E] [--phone P] [--address A]
+  customer get    --id ID
+  account create  --number N --balance B --customer ID [--product P]
+  account get     --number N
+  account list    [--bookmark KEY]
+  account customer --id ID
+  account owner   --name NAME
+  account update  --number N [--owner NAME] [--tier TIER]
+  account delete  --number N
+  account history --number N [--from T] [--to T]
+  account balance-at --number N --at T
+  transfer        --from N --to N --amount A [--request-id ID]
+  transfer get    --request-id ID
+  card create     --number N --account N
+  card get        --number N
+  card list       --account N`
+
+// command - a leaf command: defines its flags and runs against a backend
+type command func(b backend.Backend, flags *flag.FlagSet, args []string) ([]byte, error)
+
+var commands = map[string]map[string]command{
+	"customer": {
+		"create": customerCreate,
+		"get":    customerGet,
+	},
+	"account": {
+		"create":     accountCreate,
+		"get":        accountGet,
+		"list":       accountList,
+		"customer":   accountCustomer,
+		"owner":      accountOwner,
+		"update":     accountUpdate,
+		"delete":     accountDelete,
+		"history":    accountHistory,
+		"balance-at": accountBalanceAt,
+	},
+	"transfer": {
+		"":    transferMoney,
+		"get": transferGet,
+	},
+	"card": {
+		"create": cardCreate,
+		"get":    cardGet,
+		"list":   cardList,
+	},
+}
+
+// Run - Runs the command in args (e.g. "account get --number 1") and writes
+// the indented JSON result to out
+func Run(b backend.Backend, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return errors.New("missing command\n" + Usage)
+	}
+
+	subcommands, ok := commands[args[0]]
+	if !ok {
+		return errors.Errorf("unknown command `%s`\n%s", args[0], Usage)
+	}
+
+	// A command may run without subcommand (e.g. "transfer --from 1 ...")
+	name, rest := args[0], args[1:]
+	run, ok := subcommands[""]
+	if len(rest) > 0 && rest[0] != "" {
+		if subcommand, found := subcommands[rest[0]]; found {
+			run, ok = subcommand, true
+			name, rest = name+" "+rest[0], rest[1:]
+		}
+	}
+	if !ok {
+		if len(rest) == 0 {
+			return errors.Errorf("missing `%s` subcommand\n%s", args[0], Usage)
+		}
+		return errors.Errorf("unknown `%s` subcommand `%s`\n%s", args[0], rest[0], Usage)
+	}
+
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(out)
+	result, err := run(b, flags, rest)
+	if err != nil {
+		return err
+	}
+	return prettyPrint(out, result)
+}
+
+func customerCreate(b backend.Backend, flags *flag.FlagSet, args []string) ([]byte, error) {
+	id := flags.String("id", "", "customer ID")
+	legalName := flags.String("legal-name", "", "legal name")
+	documentID := flags.String("document-id", "", "identity document ID (only its hash is stored)")
+	email := flags.String("email", "", "contact email")
+	phone := flags.String("phone", "", "contact phone")
+	address := flags.String("address", "", "contact address")
+	if err := parse(flags, args, "id", "legal-name", "document-id"); err != nil {
+		return nil, err
+	}
+
+	customerAsBytes, err := json.Marshal(map[string]interface{}{
+		"id":         *id,
+		"legalName":  *legalName,
+		"documentId": *documentID,
+		"contact":    account.Contact{Email: *email, Phone: *phone, Address: *address},
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot marshal customer")
+	}
+	salt, err := newPIISalt()
+	if err != nil {
+		return nil, err
+	}
+	transient := map[string][]byte{"customer": customerAsBytes, "piiSalt": salt}
+	return b.InvokeTransient(backend.AccountChaincode, "CreateCustomer", transient)
+}
+
+func customerGet(b backend.Backend, flags *flag.FlagSet, args []string) ([]byte, error) {
+	id := flags.String("id", "", "customer ID")
+	if err := parse(flags, args, "id"); err != nil {
+		return nil, err
+	}
+	return b.Query(backend.AccountChaincode, "GetCustomer", *id)
+}
+
+func accountCreate(b backend.Backend, flags *flag.FlagSet, args []string) ([]byte, error) {
+	number := flags.Int("number", 0, "account number")
+	balance := flags.Int("balance", 0, "initial balance")
+	customer := flags.String("customer", "", "customer ID")
+	product := flags.String("product", "", "account product (default checking)")
+	if err := parse(flags, args, "number", "customer"); err != nil {
+		return nil, err
+	}
+	return b.Invoke(backend.AccountChaincode, "Create", strconv.Itoa(*number), strconv.Itoa(*balance), *customer, *product)
+}
+
+func accountGet(b backend.Backend, flags *flag.FlagSet, args []string) ([]byte, error) {
+	number := flags.Int("number", 0, "account number")
+	if err := parse(flags, args, "number"); err != nil {
+		return nil, err
+	}
+	return b.Query(backend.AccountChaincode, "GetByNumber", strconv.Itoa(*number))
+}
+
+func accountList(b backend.Backend, flags *flag.FlagSet, args []string) ([]byte, error) {
+	bookmark := flags.String("bookmark", "", "bookmark of a truncated list, to get its next accounts")
+	if err := parse(flags, args); err != nil {
+		return nil, err
+	}
+	return b.Query(backend.AccountChaincode, "GetAll", *bookmark)
+}
+
+func accountCustomer(b backend.Backend, flags *flag.FlagSet, args []string) ([]byte, error) {
+	id := flags.String("id", "", "customer ID")
+	if err := parse(flags, args, "id"); err != nil {
+		return nil, err
+	}
+	return b.Query(backend.AccountChaincode, "GetByCustomer", *id)
+}
+
+func accountOwner(b backend.Backend, flags *flag.FlagSet, args []string) ([]byte, error) {
+	name := flags.String("name", "", "owner name")
+	if err := parse(flags, args, "name"); err != nil {
+		return nil, err
+	}
+	return b.Query(backend.AccountChaincode, "SearchByOwner", *name)
+}
+
+// accountUpdate - Reads the account, applies the given flags and rewrites it,
+// so the caller never writes the account JSON by hand. The account goes in the
+// transient map, keeping the owner name off the ledger
+func accountUpdate(b backend.Backend, flags *flag.FlagSet, args []string) ([]byte, error) {
+	number := flags.Int("number", 0, "account number")
+	owner := flags.String("owner", "", "new owner name")
+	tier := flags.String("tier", "", "new transfer limits tier, empty for the one of the product")
+	if err := parse(flags, args, "number"); err != nil {
+		return nil, err
+	}
+
+	accountAsBytes, err := b.Query(backend.AccountChaincode, "GetByNumber", strconv.Itoa(*number))
+	if err != nil {
+		return nil, err
+	}
+	var acc account.Account
+	if err := json.Unmarshal(accountAsBytes, &acc); err != nil {
+		return nil, errors.Wrap(err, "cannot unmarshal account")
+	}
+
+	flags.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "owner":
+			acc.AccountOwner = *owner
+		case "tier":
+			acc.Tier = *tier
+		}
+	})
+
+	accountAsBytes, err = json.Marshal(acc)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot marshal account")
+	}
+	salt, err := newPIISalt()
+	if err != nil {
+		return nil, err
+	}
+	transient := map[string][]byte{"account": accountAsBytes, "piiSalt": salt}
+	if _, err := b.InvokeTransient(backend.AccountChaincode, "Update", transient); err != nil {
+		return nil, err
+	}
+	return accountAsBytes, nil
+}
+
+// newPIISalt - Returns a random secret salting the personal data written by a
+// transaction, so that its hashes on the ledger cannot be matched against
+// guessed names
+func newPIISalt() ([]byte, error) {
+	salt := make([]byte, 32)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, errors.Wrap(err, "cannot generate PII salt")
+	}
+	return salt, nil
+}
+
+func accountDelete(b backend.Backend, flags *flag.FlagSet, args []string) ([]byte, error) {
+	number := flags.Int("number", 0, "account number")
+	if err := parse(flags, args, "number"); err != nil {
+		return nil, err
+	}
+	return b.Invoke(backend.AccountChaincode, "Delete", strconv.Itoa(*number))
+}
+
+func accountHistory(b backend.Backend, flags *flag.FlagSet, args []string) ([]byte, error) {
+	number := flags.Int("number", 0, "account number")
+	from := flags.String("from", "", "start of the time range, RFC 3339")
+	to := flags.String("to", "", "end of the time range, RFC 3339")
+	if err := parse(flags, args, "number"); err != nil {
+		return nil, err
+	}
+	if *from != "" || *to != "" {
+		return b.Query(backend.AccountChaincode, "GetHistoryBetween", strconv.Itoa(*number), *from, *to)
+	}
+	return b.Query(backend.AccountChaincode, "GetHistory", strconv.Itoa(*number))
+}
+
+func accountBalanceAt(b backend.Backend, flags *flag.FlagSet, args []string) ([]byte, error) {
+	number := flags.Int("number", 0, "account number")
+	at := flags.String("at", "", "instant, RFC 3339")
+	if err := parse(flags, args, "number", "at"); err != nil {
+		return nil, err
+	}
+	return b.Query(backend.AccountChaincode, "GetBalanceAt", strconv.Itoa(*number), *at)
+}
+
+func transferMoney(b backend.Backend, flags *flag.FlagSet, args []string) ([]byte, error) {
+	from := flags.Int("from", 0, "payer account number")
+	to := flags.Int("to", 0, "receiver account number")
+	amount := flags.Int("amount", 0, "amount to transfer")
+	requestID := flags.String("request-id", "", "client request ID, makes retries safe")
+	if err := parse(flags, args, "from", "to", "amount"); err != nil {
+		return nil, err
+	}
+
+	moneyArgs := []string{strconv.Itoa(*from), strconv.Itoa(*to), strconv.Itoa(*amount)}
+	if *requestID != "" {
+		moneyArgs = append(moneyArgs, *requestID)
+	}
+	return b.Invoke(backend.TransferChaincode, "Money", moneyArgs...)
+}
+
+func transferGet(b backend.Backend, flags *flag.FlagSet, args []string) ([]byte, error) {
+	requestID := flags.String("request-id", "", "client request ID given to the transfer")
+	if err := parse(flags, args, "request-id"); err != nil {
+		return nil, err
+	}
+	return b.Query(backend.TransferChaincode, "GetByRequestID", *requestID)
+}
+
+func cardCreate(b backend.Backend, flags *flag.FlagSet, args []string) ([]byte, error) {
+	number := flags.Int("number", 0, "card number")
+	accNumber := flags.Int("account", 0, "account number")
+	if err := parse(flags, args, "number", "account"); err != nil {
+		return nil, err
+	}
+	return b.Invoke(backend.CardChaincode, "Create", strconv.Itoa(*number), strconv.Itoa(*accNumber))
+}
+
+func cardGet(b backend.Backend, flags *flag.FlagSet, args []string) ([]byte, error) {
+	number := flags.Int("number", 0, "card number")
+	if err := parse(flags, args, "number"); err != nil {
+		return nil, err
+	}
+	return b.Query(backend.CardChaincode, "GetByNumber", strconv.Itoa(*number))
+}
+
+func cardList(b backend.Backend, flags *flag.FlagSet, args []string) ([]byte, error) {
+	accNumber := flags.Int("account", 0, "account number")
+	if err := parse(flags, args, "account"); err != nil {
+		return nil, err
+	}
+	return b.Query(backend.CardChaincode, "GetByAccount", strconv.Itoa(*accNumber))
+}
+
+// parse - Parses flags and checks that the required ones were given
+func parse(flags *flag.FlagSet, args []string, required ...string) error {
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() > 0 {
+		return errors.Errorf("%s: unexpected argument `%s`", flags.Name(), flags.Arg(0))
+	}
+
+	given := make(map[string]bool)
+	flags.Visit(func(f *flag.Flag) {
+		given[f.Name] = true
+	})
+	for _, name := range required {
+		if !given[name] {
+			return errors.Errorf("%s: missing required flag --%s", flags.Name(), name)
+		}
+	}
+	return nil
+}
+
+// prettyPrint - Indents JSON results; other payloads are printed as-is
+func prettyPrint(out io.Writer, result []byte) error {
+	if len(result) == 0 {
+		_, err := fmt.Fprintln(out, "OK")
+		return err
+	}
+
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, result, "", "  "); err != nil {
+		_, err = fmt.Fprintln(out, string(result))
+		return err
+	}
+	_, err := fmt.Fprintln(out, indented.String())
+	return err
+}
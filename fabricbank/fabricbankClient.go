@@ -0,0 +1,105 @@
+/*
+==== Against the basic-network peer ====
+fabricbank customer create --id C1 --legal-name "Elcius Ferreira" --document-id 123456789
+fabricbank account create --number 1 --balance 1000 --customer C1
+fabricbank account update --number 1 --tier business
+fabricbank transfer --from 1 --to 2 --amount 500
+fabricbank card list --account 1
+
+==== Offline (MockStub), state kept in a file between runs ====
+fabricbank --offline --state test.json customer create --id C1 --legal-name "Elcius Ferreira" --document-id 123456789
+fabricbank --offline --state test.json account create --number 1 --balance 1000 --customer C1
+fabricbank --offline --state test.json account get --number 1
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/hyperledger-fabric-go-chaincodes/backend"
+	"github.com/hyperledger-fabric-go-chaincodes/fabricbank/cli"
+)
+
+func main() {
+	configPath := flag.String("config", "../basic-network/connection.yaml", "Fabric SDK connection profile")
+	channel := flag.String("channel", "mychannel", "channel of the chaincodes")
+	user := flag.String("user", "User1", "identity submitting the transactions")
+	offline := flag.Bool("offline", false, "run the chaincodes in memory (MockStub) instead of using the network")
+	statePath := flag.String("state", "fabricbank-state.json", "offline mode: file keeping the world state and private data between runs")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: fabricbank [flags] <command> [subcommand] [command flags]")
+		flag.PrintDefaults()
+		fmt.Fprintln(os.Stderr, cli.Usage)
+	}
+	flag.Parse()
+
+	if *offline {
+		mock, err := backend.NewMock()
+		if err != nil {
+			exit(err)
+		}
+		if err := loadState(mock, *statePath); err != nil {
+			exit(err)
+		}
+		// A failed command leaves the state file as it was
+		if err := cli.Run(mock, flag.Args(), os.Stdout); err != nil {
+			exit(err)
+		}
+		if err := saveState(mock, *statePath); err != nil {
+			exit(err)
+		}
+		return
+	}
+
+	sdk, err := backend.NewSDK(*configPath, *channel, *user)
+	if err != nil {
+		exit(err)
+	}
+	defer sdk.Close()
+	if err := cli.Run(sdk, flag.Args(), os.Stdout); err != nil {
+		sdk.Close()
+		exit(err)
+	}
+}
+
+// loadState - Restores the offline world state and private data, if the file exists
+func loadState(mock *backend.Mock, path string) error {
+	stateAsBytes, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	snapshot := &backend.Snapshot{}
+	if err := json.Unmarshal(stateAsBytes, snapshot); err != nil {
+		return fmt.Errorf("cannot unmarshal state file %s: %v", path, err)
+	}
+	if snapshot.State == nil && snapshot.Private == nil {
+		// State files of earlier versions only hold the world state, by chaincode
+		if err := json.Unmarshal(stateAsBytes, &snapshot.State); err != nil {
+			return fmt.Errorf("cannot unmarshal state file %s: %v", path, err)
+		}
+	}
+	return mock.Restore(snapshot)
+}
+
+// saveState - Persists the offline world state and private data for the next run
+func saveState(mock *backend.Mock, path string) error {
+	stateAsBytes, err := json.MarshalIndent(mock.Snapshot(), "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, stateAsBytes, 0644)
+}
+
+// exit - Prints the error and exits with a non-zero status, for scripting
+func exit(err error) {
+	fmt.Fprintln(os.Stderr, "Error:", err)
+	os.Exit(1)
+}
@@ -0,0 +1,212 @@
+package query
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strconv"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// Page sizes of ad-hoc queries
+const (
+	DefaultPageSize = 25
+	MaxPageSize     = 100
+)
+
+// Ad-hoc query operators
+const (
+	OpEq    = "$eq"
+	OpNe    = "$ne"
+	OpGt    = "$gt"
+	OpGte   = "$gte"
+	OpLt    = "$lt"
+	OpLte   = "$lte"
+	OpIn    = "$in"
+	OpRegex = "$regex"
+)
+
+// Comparison operators, allowed on ordered fields such as amounts
+var Comparison = []string{OpEq, OpNe, OpGt, OpGte, OpLt, OpLte, OpIn}
+
+// Allowlist maps the fields an ad-hoc query may filter on to their operators
+type Allowlist map[string][]string
+
+// AdHoc structure with an ad-hoc query as sent by clients. The selector maps
+// fields to a value (equality) or to an object of operators and values, e.g.
+// {"selector":{"accountBalance":{"$gt":10000},"customerId":"C1"},"limit":10}.
+// Fields optionally projects the records on allowlisted fields
+type AdHoc struct {
+	Selector map[string]json.RawMessage `json:"selector"`
+	Fields   []string                   `json:"fields"`
+	Limit    int                        `json:"limit"`
+	Bookmark string                     `json:"bookmark"`
+}
+
+// Page structure with a page of results of a paginated query. Bookmark is
+// passed back to get the next page. A truncated page left out records over the
+// byte budget of a response, which a smaller limit gets back
+type Page struct {
+	Records   []Record `json:"records"`
+	Count     int32    `json:"fetchedRecordsCount"`
+	Bookmark  string   `json:"bookmark"`
+	Truncated bool     `json:"truncated"`
+}
+
+// ParseAdHoc - Parses an ad-hoc query on the documents of docType. Only the
+// fields and operators of the allowlist are accepted, with scalar values, and
+// records are only projected on allowlisted fields. Returns
+// the query, its page size (DefaultPageSize when no limit is given, at most
+// MaxPageSize) and the bookmark of the page
+func ParseAdHoc(adHocAsString string, docType string, allowlist Allowlist) (*Query, int32, string, error) {
+	adHoc := AdHoc{}
+	decoder := json.NewDecoder(bytes.NewReader([]byte(adHocAsString)))
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(&adHoc)
+	if err != nil {
+		return nil, 0, "", errors.New("Query not valid as json object: " + err.Error())
+	}
+	if adHoc.Limit < 0 || adHoc.Limit > MaxPageSize {
+		return nil, 0, "", errors.New("Query limit must be between 1 and " + strconv.Itoa(MaxPageSize))
+	}
+	pageSize := int32(adHoc.Limit)
+	if pageSize == 0 {
+		pageSize = DefaultPageSize
+	}
+
+	selector := NewSelector().Eq("docType", docType)
+	for field, condition := range adHoc.Selector {
+		operators, ok := allowlist[field]
+		if !ok {
+			return nil, 0, "", errors.New("Field " + field + " cannot be queried")
+		}
+
+		// A plain value stands for equality
+		conditions := map[string]json.RawMessage{}
+		if err := json.Unmarshal(condition, &conditions); err != nil {
+			conditions = map[string]json.RawMessage{OpEq: condition}
+		}
+		if len(conditions) == 0 {
+			return nil, 0, "", errors.New("Field " + field + " has no condition")
+		}
+
+		for operator, valueAsBytes := range conditions {
+			if !contains(operators, operator) {
+				return nil, 0, "", errors.New("Operator " + operator + " not allowed on field " + field)
+			}
+			err = addCondition(selector, field, operator, valueAsBytes)
+			if err != nil {
+				return nil, 0, "", err
+			}
+		}
+	}
+
+	for _, field := range adHoc.Fields {
+		if _, ok := allowlist[field]; !ok {
+			return nil, 0, "", errors.New("Field " + field + " cannot be projected")
+		}
+	}
+
+	return NewQuery(selector).Fields(adHoc.Fields...), pageSize, adHoc.Bookmark, nil
+}
+
+// GetQueryResultWithPagination - Executes a query page by page. The page is
+// returned as a JSON Page
+func GetQueryResultWithPagination(stub shim.ChaincodeStubInterface, q *Query, pageSize int32, bookmark string) ([]byte, error) {
+	queryString, err := q.Build()
+	if err != nil {
+		return nil, err
+	}
+
+	resultsIterator, metadata, err := stub.GetQueryResultWithPagination(queryString, pageSize, bookmark)
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	response, err := BuildResponse(resultsIterator, Options{Fields: q.fields})
+	if err != nil {
+		return nil, err
+	}
+
+	page := Page{Records: response.Records, Truncated: response.Metadata.Truncated}
+	if metadata != nil {
+		page.Count = metadata.FetchedRecordsCount
+		page.Bookmark = metadata.Bookmark
+	}
+	pageAsBytes, err := json.Marshal(page)
+	if err != nil {
+		return nil, errors.New("Cannot marshal page: " + err.Error())
+	}
+	return pageAsBytes, nil
+}
+
+// addCondition - Checks the value of an ad-hoc condition and adds it to the selector
+func addCondition(selector *Selector, field string, operator string, valueAsBytes json.RawMessage) error {
+	var value interface{}
+	if err := json.Unmarshal(valueAsBytes, &value); err != nil {
+		return errors.New("Value of " + field + " not valid: " + err.Error())
+	}
+
+	if operator == OpIn {
+		values, ok := value.([]interface{})
+		if !ok {
+			return errors.New("Value of " + field + " " + operator + " must be an array")
+		}
+		for _, v := range values {
+			if !scalar(v) {
+				return errors.New("Values of " + field + " " + operator + " must be strings, numbers or booleans")
+			}
+		}
+		selector.In(field, values)
+		return nil
+	}
+	if !scalar(value) {
+		return errors.New("Value of " + field + " " + operator + " must be a string, number or boolean")
+	}
+
+	switch operator {
+	case OpEq:
+		selector.Eq(field, value)
+	case OpNe:
+		selector.Ne(field, value)
+	case OpGt:
+		selector.Gt(field, value)
+	case OpGte:
+		selector.Range(field, value, nil)
+	case OpLt:
+		selector.Lt(field, value)
+	case OpLte:
+		selector.Range(field, nil, value)
+	case OpRegex:
+		pattern, ok := value.(string)
+		if !ok {
+			return errors.New("Value of " + field + " " + operator + " must be a string")
+		}
+		selector.Regex(field, pattern)
+	default:
+		return errors.New("Unknown operator " + operator)
+	}
+	return nil
+}
+
+// scalar - Tells whether a JSON value is a string, number or boolean
+func scalar(value interface{}) bool {
+	switch value.(type) {
+	case string, float64, bool:
+		return true
+	default:
+		return false
+	}
+}
+
+// contains - Tells whether a slice holds a string
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
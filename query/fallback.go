@@ -0,0 +1,85 @@
+package query
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// Unsupported - Tells whether a query error comes from a state database without
+// rich queries: LevelDB, or the MockStub
+func Unsupported(err error) bool {
+	if err == nil {
+		return false
+	}
+	message := strings.ToLower(err.Error())
+	return strings.Contains(message, "not supported for leveldb") || strings.Contains(message, "not implemented")
+}
+
+// GetQueryResultOrFallback - Executes a query built with NewQuery. When the state
+// database does not support rich queries, the fallback, typically walking a
+// composite key index, is run instead
+func GetQueryResultOrFallback(stub shim.ChaincodeStubInterface, q *Query, fallback func() ([]byte, error)) ([]byte, error) {
+	queryResult, err := GetQueryResult(stub, q)
+	if Unsupported(err) {
+		return fallback()
+	}
+	return queryResult, err
+}
+
+// GetIndexedKeys - Returns the last attribute of the composite keys of index
+// starting with the given attributes, e.g. the accounts of an owner in an
+// owner~account index. An empty collection walks the public state
+func GetIndexedKeys(stub shim.ChaincodeStubInterface, collection string, index string, attributes ...string) ([]string, error) {
+	var indexIterator shim.StateQueryIteratorInterface
+	var err error
+	if collection == "" {
+		indexIterator, err = stub.GetStateByPartialCompositeKey(index, attributes)
+	} else {
+		indexIterator, err = stub.GetPrivateDataByPartialCompositeKey(collection, index, attributes)
+	}
+	if err != nil {
+		return nil, errors.New("Failed to query index " + index + ": " + err.Error())
+	}
+	defer indexIterator.Close()
+
+	keys := []string{}
+	for indexIterator.HasNext() {
+		indexEntry, err := indexIterator.Next()
+		if err != nil {
+			return nil, errors.New("Cannot iterate index " + index + ": " + err.Error())
+		}
+		_, keyParts, err := stub.SplitCompositeKey(indexEntry.Key)
+		if err != nil {
+			return nil, errors.New("Failed to split key of index " + index + ": " + err.Error())
+		}
+		if len(keyParts) > 0 {
+			keys = append(keys, keyParts[len(keyParts)-1])
+		}
+	}
+	return keys, nil
+}
+
+// ConstructQueryResponseFromKeys - Constructs a JSON Response containing query
+// results, as ConstructQueryResponseFromIterator does, from the given state
+// keys. Missing states are skipped
+func ConstructQueryResponseFromKeys(stub shim.ChaincodeStubInterface, keys []string) ([]byte, error) {
+	b := newBuilder(Options{})
+	for _, key := range keys {
+		value, err := stub.GetState(key)
+		if err != nil {
+			return nil, err
+		} else if value == nil {
+			continue
+		}
+		err = b.add(key, value)
+		if err != nil {
+			return nil, err
+		}
+		if b.response.Metadata.Truncated {
+			break
+		}
+	}
+	return b.marshal()
+}
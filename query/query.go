@@ -5,76 +5,44 @@ get data from couchdb database.
 package query
 
 import (
-	"bytes"
-	"fmt"
-
 	"github.com/hyperledger/fabric/core/chaincode/shim"
 )
 
-// ConstructQueryResponseFromIterator - Constructs a JSON array containing query results from
-// a given result iterator
+// ConstructQueryResponseFromIterator - Constructs a JSON Response containing query results from
+// a given result iterator, within the default budget of a response. Results
+// beyond the budget are left out and reported in the metadata of the response
 func ConstructQueryResponseFromIterator(resultsIterator shim.StateQueryIteratorInterface) ([]byte, error) {
-	fmt.Println("[DEBUG] begin query.ConstructQueryResponseFromIterator")
-
-	// Buffer is a JSON array containing QueryResults
-	var b bytes.Buffer
-	b.WriteString("[")
-
-	bArrayMemberAlreadyWritten := false
-	for resultsIterator.HasNext() {
-		queryResponse, err := resultsIterator.Next()
-		if err != nil {
-			return nil, err
-		}
-
-		// Add a comma before array members, suppress it for the first array member
-		if bArrayMemberAlreadyWritten == true {
-			b.WriteString(",")
-		}
-		b.WriteString("{\"Key\":")
-		b.WriteString("\"")
-		b.WriteString(queryResponse.Key)
-		b.WriteString("\"")
-		b.WriteString(", \"Record\":")
-		// Record is a JSON object, so we write as-is
-		b.WriteString(string(queryResponse.Value[:]))
-		b.WriteString("}")
-
-		bArrayMemberAlreadyWritten = true
+	b := newBuilder(Options{})
+	err := b.addAll(resultsIterator)
+	if err != nil {
+		return nil, err
 	}
-
-	b.WriteString("]")
-
-	fmt.Println("[DEBUG] end query.ConstructQueryResponseFromIterator")
-	return b.Bytes(), nil
+	return b.marshal()
 }
 
 // GetQueryResultForQueryString - Executes the passed in query string.
-// Result set is built and returned as a byte array containing the JSON results.
+// Result set is built and returned as a byte array containing the JSON Response.
 func GetQueryResultForQueryString(stub shim.ChaincodeStubInterface, queryString string) ([]byte, error) {
-	fmt.Println("[DEBUG] begin query.GetQueryResultForQueryString")
-	fmt.Printf("[DEBUG] queryString:\n%s\n", queryString)
-
-	var queryResult []byte
-
 	// Query couchdb
 	resultsIterator, err := stub.GetQueryResult(queryString)
 	if err != nil {
 		return nil, err
 	}
-
 	defer resultsIterator.Close()
 
-	// Check if there are query results
-	if resultsIterator.HasNext() {
-		// Format query response
-		queryResult, err = ConstructQueryResponseFromIterator(resultsIterator)
-		if err != nil {
-			return nil, err
-		}
+	return ConstructQueryResponseFromIterator(resultsIterator)
+}
+
+// GetPrivateQueryResultForQueryString - Executes the passed in query string on a
+// private data collection.
+// Result set is built and returned as a byte array containing the JSON Response.
+func GetPrivateQueryResultForQueryString(stub shim.ChaincodeStubInterface, collection string, queryString string) ([]byte, error) {
+	// Query couchdb
+	resultsIterator, err := stub.GetPrivateDataQueryResult(collection, queryString)
+	if err != nil {
+		return nil, err
 	}
+	defer resultsIterator.Close()
 
-	fmt.Printf("[DEBUG] queryResult:\n%s\n", queryResult)
-	fmt.Println("[DEBUG] end query.GetQueryResultForQueryString")
-	return queryResult, nil
+	return ConstructQueryResponseFromIterator(resultsIterator)
 }
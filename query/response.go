@@ -0,0 +1,180 @@
+package query
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strconv"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// Default budget of a query response. The response is returned in a single
+// gRPC message to the peer, so it is kept well below its size limit
+const (
+	DefaultMaxRecords = 1000
+	DefaultMaxBytes   = 1 << 20
+)
+
+// Options structure with the budget of a query response and the fields of the
+// records to keep. A zero budget stands for the default one; no fields keeps
+// the whole records
+type Options struct {
+	MaxRecords int
+	MaxBytes   int
+	Fields     []string
+}
+
+// Record structure with a query result, as in the JSON arrays of results
+type Record struct {
+	Key    string          `json:"Key"`
+	Record json.RawMessage `json:"Record"`
+}
+
+// Metadata structure describing a query response. Truncated tells that more
+// results matched than the budget allowed; Bookmark is then the key of the
+// first result left out, from which a range query can resume
+type Metadata struct {
+	Count     int    `json:"count"`
+	Bytes     int    `json:"bytes"`
+	Truncated bool   `json:"truncated"`
+	Bookmark  string `json:"bookmark,omitempty"`
+}
+
+// Response structure with the results of a query and their metadata
+type Response struct {
+	Records  []Record `json:"records"`
+	Metadata Metadata `json:"metadata"`
+}
+
+// BuildResponse - Builds a response from a result iterator, reading no more
+// results than the budget of the options allows. Keys are escaped, records
+// that are not JSON are returned as JSON strings and objects are projected on
+// the fields of the options
+func BuildResponse(resultsIterator shim.StateQueryIteratorInterface, options Options) (*Response, error) {
+	b := newBuilder(options)
+	err := b.addAll(resultsIterator)
+	if err != nil {
+		return nil, err
+	}
+	return b.response, nil
+}
+
+// builder - Adds records to a response within its budget
+type builder struct {
+	options  Options
+	response *Response
+}
+
+// newBuilder - Creates a builder of an empty response, filling in the default budget
+func newBuilder(options Options) *builder {
+	if options.MaxRecords <= 0 {
+		options.MaxRecords = DefaultMaxRecords
+	}
+	if options.MaxBytes <= 0 {
+		options.MaxBytes = DefaultMaxBytes
+	}
+	return &builder{options: options, response: &Response{Records: []Record{}}}
+}
+
+// full - Tells whether the response holds as many records as the budget allows
+func (b *builder) full() bool {
+	return b.response.Metadata.Count >= b.options.MaxRecords
+}
+
+// add - Adds a record to the response. A record over the budget is left out
+// and marks the response as truncated
+func (b *builder) add(key string, value []byte) error {
+	if b.response.Metadata.Truncated {
+		return nil
+	}
+	if b.full() {
+		b.truncate(key)
+		return nil
+	}
+
+	record, err := b.record(key, value)
+	if err != nil {
+		return err
+	}
+	recordAsBytes, err := json.Marshal(record)
+	if err != nil {
+		return errors.New("Cannot marshal record " + strconv.Quote(key) + ": " + err.Error())
+	}
+	// A comma separates the records of the array
+	size := len(recordAsBytes) + 1
+	if b.response.Metadata.Bytes+size > b.options.MaxBytes {
+		b.truncate(key)
+		return nil
+	}
+
+	b.response.Records = append(b.response.Records, record)
+	b.response.Metadata.Count++
+	b.response.Metadata.Bytes += size
+	return nil
+}
+
+// truncate - Marks the response as truncated from the record of key on
+func (b *builder) truncate(key string) {
+	b.response.Metadata.Truncated = true
+	b.response.Metadata.Bookmark = key
+}
+
+// addAll - Adds the results of an iterator until the budget is spent. The
+// iterator is not read any further once the response is truncated
+func (b *builder) addAll(resultsIterator shim.StateQueryIteratorInterface) error {
+	for !b.response.Metadata.Truncated && resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return err
+		}
+		err = b.add(queryResponse.Key, queryResponse.Value)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// record - Makes the record of a result, projected on the fields of the options
+func (b *builder) record(key string, value []byte) (Record, error) {
+	if !json.Valid(value) {
+		valueAsBytes, err := json.Marshal(string(value))
+		if err != nil {
+			return Record{}, errors.New("Cannot marshal record " + strconv.Quote(key) + ": " + err.Error())
+		}
+		return Record{Key: key, Record: valueAsBytes}, nil
+	}
+	if len(b.options.Fields) == 0 {
+		return Record{Key: key, Record: value}, nil
+	}
+
+	// Only objects have fields to project on
+	document := map[string]json.RawMessage{}
+	if err := json.Unmarshal(value, &document); err != nil {
+		return Record{Key: key, Record: value}, nil
+	}
+	projection := make(map[string]json.RawMessage, len(b.options.Fields))
+	for _, field := range b.options.Fields {
+		if fieldValue, ok := document[field]; ok {
+			projection[field] = fieldValue
+		}
+	}
+	projectionAsBytes, err := json.Marshal(projection)
+	if err != nil {
+		return Record{}, errors.New("Cannot marshal record " + strconv.Quote(key) + ": " + err.Error())
+	}
+	return Record{Key: key, Record: projectionAsBytes}, nil
+}
+
+// marshal - Returns the response as JSON
+func (b *builder) marshal() ([]byte, error) {
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetEscapeHTML(false)
+	err := encoder.Encode(b.response)
+	if err != nil {
+		return nil, errors.New("Cannot marshal query results: " + err.Error())
+	}
+	return bytes.TrimRight(buffer.Bytes(), "\n"), nil
+}
@@ -0,0 +1,256 @@
+package query
+
+import (
+	"encoding/json"
+	"errors"
+	"regexp"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// Sort orders
+const (
+	Asc  = "asc"
+	Desc = "desc"
+)
+
+// Selector is a CouchDB selector built clause by clause. It is marshaled with
+// encoding/json, so values can never alter the structure of the selector
+type Selector struct {
+	clauses map[string]interface{}
+	err     error
+}
+
+// NewSelector - Creates an empty selector, matching every document
+func NewSelector() *Selector {
+	return &Selector{clauses: make(map[string]interface{})}
+}
+
+// Eq - Matches documents whose field equals value
+func (s *Selector) Eq(field string, value interface{}) *Selector {
+	return s.operator(field, "$eq", value)
+}
+
+// Ne - Matches documents whose field differs from value
+func (s *Selector) Ne(field string, value interface{}) *Selector {
+	return s.operator(field, "$ne", value)
+}
+
+// Gt - Matches documents whose field is greater than value
+func (s *Selector) Gt(field string, value interface{}) *Selector {
+	return s.operator(field, "$gt", value)
+}
+
+// Lt - Matches documents whose field is less than value
+func (s *Selector) Lt(field string, value interface{}) *Selector {
+	return s.operator(field, "$lt", value)
+}
+
+// In - Matches documents whose field equals one of the values, given as a slice
+func (s *Selector) In(field string, values interface{}) *Selector {
+	return s.operator(field, "$in", values)
+}
+
+// Range - Matches documents whose field is between min and max, both included.
+// A nil bound is left open
+func (s *Selector) Range(field string, min interface{}, max interface{}) *Selector {
+	if min != nil {
+		s.operator(field, "$gte", min)
+	}
+	if max != nil {
+		s.operator(field, "$lte", max)
+	}
+	return s
+}
+
+// Regex - Matches documents whose field matches the regular expression
+func (s *Selector) Regex(field string, pattern string) *Selector {
+	if _, err := regexp.Compile(pattern); err != nil {
+		s.fail(errors.New("invalid regex for " + field + ": " + err.Error()))
+		return s
+	}
+	return s.operator(field, "$regex", pattern)
+}
+
+// ElemMatch - Matches documents whose array field has an element matching elem
+func (s *Selector) ElemMatch(field string, elem *Selector) *Selector {
+	s.fail(elem.err)
+	return s.operator(field, "$elemMatch", elem)
+}
+
+// Or - Matches documents matching any of the alternatives, on top of the other
+// clauses of the selector
+func (s *Selector) Or(alternatives ...*Selector) *Selector {
+	for _, alternative := range alternatives {
+		s.fail(alternative.err)
+	}
+	s.clauses["$or"] = alternatives
+	return s
+}
+
+// Filters - Tells whether the selector has a condition on field, outside of $or
+// alternatives. CouchDB only runs a query on an index when it has one on every
+// field of the index
+func (s *Selector) Filters(field string) bool {
+	_, ok := s.clauses[field]
+	return ok
+}
+
+// MarshalJSON - Marshals the selector clauses
+func (s *Selector) MarshalJSON() ([]byte, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	return json.Marshal(s.clauses)
+}
+
+// operator - Adds a condition on a field, next to the other conditions on it
+func (s *Selector) operator(field string, operator string, value interface{}) *Selector {
+	conditions, ok := s.clauses[field].(map[string]interface{})
+	if !ok {
+		conditions = make(map[string]interface{})
+		s.clauses[field] = conditions
+	}
+	conditions[operator] = value
+	return s
+}
+
+// fail - Keeps the first error, returned when the query is built
+func (s *Selector) fail(err error) {
+	if s.err == nil {
+		s.err = err
+	}
+}
+
+// Query is a CouchDB query: a selector plus sort, fields, limit and index
+type Query struct {
+	selector *Selector
+	sort     []map[string]string
+	fields   []string
+	limit    int
+	useIndex []string
+}
+
+// NewQuery - Creates a query of the documents matching the selector
+func NewQuery(selector *Selector) *Query {
+	return &Query{selector: selector}
+}
+
+// Sort - Sorts the results by field, in Asc or Desc order. Sorted fields must
+// be indexed
+func (q *Query) Sort(field string, order string) *Query {
+	if order != Asc && order != Desc {
+		q.selector.fail(errors.New("invalid sort order " + order + " for " + field))
+		return q
+	}
+	q.sort = append(q.sort, map[string]string{field: order})
+	return q
+}
+
+// Fields - Returns only the given fields of the documents
+func (q *Query) Fields(fields ...string) *Query {
+	q.fields = append(q.fields, fields...)
+	return q
+}
+
+// Limit - Returns at most limit documents
+func (q *Query) Limit(limit int) *Query {
+	if limit <= 0 {
+		q.selector.fail(errors.New("limit must be positive"))
+		return q
+	}
+	q.limit = limit
+	return q
+}
+
+// UseIndex - Runs the query on the index name of the design document ddoc
+func (q *Query) UseIndex(ddoc string, name string) *Query {
+	q.useIndex = []string{"_design/" + ddoc, name}
+	return q
+}
+
+// Index names a CouchDB index of a design document on the docType and Field
+type Index struct {
+	DDoc  string
+	Name  string
+	Field string
+}
+
+// UseFirstIndex - Runs the query on the first of the indexes whose field the
+// selector filters on, or on fallback when it filters on none of them
+func (q *Query) UseFirstIndex(indexes []Index, fallback Index) *Query {
+	for _, index := range indexes {
+		if q.selector.Filters(index.Field) {
+			return q.UseIndex(index.DDoc, index.Name)
+		}
+	}
+	return q.UseIndex(fallback.DDoc, fallback.Name)
+}
+
+// MarshalJSON - Marshals the query as expected by CouchDB
+func (q *Query) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Selector *Selector           `json:"selector"`
+		Sort     []map[string]string `json:"sort,omitempty"`
+		Fields   []string            `json:"fields,omitempty"`
+		Limit    int                 `json:"limit,omitempty"`
+		UseIndex []string            `json:"use_index,omitempty"`
+	}{q.selector, q.sort, q.fields, q.limit, q.useIndex})
+}
+
+// Build - Returns the query string, or the error of an invalid query
+func (q *Query) Build() (string, error) {
+	if q.selector.err != nil {
+		return "", errors.New("Invalid query: " + q.selector.err.Error())
+	}
+	queryAsBytes, err := json.Marshal(q)
+	if err != nil {
+		return "", errors.New("Cannot marshal query: " + err.Error())
+	}
+	return string(queryAsBytes), nil
+}
+
+// GetQueryResult - Executes a query built with NewQuery.
+// Result set is built and returned as a byte array containing the JSON results.
+func GetQueryResult(stub shim.ChaincodeStubInterface, q *Query) ([]byte, error) {
+	queryString, err := q.Build()
+	if err != nil {
+		return nil, err
+	}
+	return GetQueryResultForQueryString(stub, queryString)
+}
+
+// GetPrivateQueryResult - Executes a query built with NewQuery on a private data
+// collection.
+// Result set is built and returned as a byte array containing the JSON results.
+func GetPrivateQueryResult(stub shim.ChaincodeStubInterface, collection string, q *Query) ([]byte, error) {
+	queryString, err := q.Build()
+	if err != nil {
+		return nil, err
+	}
+	return GetPrivateQueryResultForQueryString(stub, collection, queryString)
+}
+
+// GetQueryKeys - Executes a query built with NewQuery and returns the keys of
+// the matching documents
+func GetQueryKeys(stub shim.ChaincodeStubInterface, q *Query) ([]string, error) {
+	queryString, err := q.Build()
+	if err != nil {
+		return nil, err
+	}
+	resultsIterator, err := stub.GetQueryResult(queryString)
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	keys := []string{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, queryResponse.Key)
+	}
+	return keys, nil
+}
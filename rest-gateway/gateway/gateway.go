@@ -0,0 +1,178 @@
+/*
+Package gateway exposes the account, card and transfer chaincodes as a REST API.
+*/
+package gateway
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/hyperledger-fabric-go-chaincodes/backend"
+)
+
+// AccountRequest structure with the body of POST /accounts
+type AccountRequest struct {
+	AccountNumber  int    `json:"accountNumber"`
+	AccountBalance int    `json:"accountBalance"`
+	CustomerID     string `json:"customerId"`
+	Product        string `json:"product,omitempty"`
+}
+
+// TransferRequest structure with the body of POST /transfers.
+// RequestID makes retries safe: a transfer is executed once per request ID
+type TransferRequest struct {
+	From      int    `json:"from"`
+	To        int    `json:"to"`
+	Amount    int    `json:"amount"`
+	RequestID string `json:"requestId,omitempty"`
+}
+
+// Gateway routes REST requests to a backend
+type Gateway struct {
+	backend backend.Backend
+	mux     *http.ServeMux
+}
+
+// New - Creates the gateway handler for a backend
+func New(b backend.Backend) *Gateway {
+	g := &Gateway{backend: b, mux: http.NewServeMux()}
+	g.mux.HandleFunc("/accounts", g.accounts)
+	g.mux.HandleFunc("/accounts/", g.account)
+	g.mux.HandleFunc("/transfers", g.transfers)
+	g.mux.HandleFunc("/cards", g.cards)
+	return g
+}
+
+// ServeHTTP - Implements http.Handler
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	g.mux.ServeHTTP(w, r)
+}
+
+// accounts - POST /accounts creates an account
+func (g *Gateway) accounts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	var request AccountRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid account: "+err.Error())
+		return
+	}
+
+	accNumber := strconv.Itoa(request.AccountNumber)
+	_, err := g.backend.Invoke(backend.AccountChaincode, "Create", accNumber, strconv.Itoa(request.AccountBalance), request.CustomerID, request.Product)
+	if err != nil {
+		writeBackendError(w, err)
+		return
+	}
+
+	w.Header().Set("Location", "/accounts/"+accNumber)
+	writeJSON(w, http.StatusCreated, request)
+}
+
+// account - GET /accounts/{n} returns an account
+func (g *Gateway) account(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	accNumber := strings.TrimPrefix(r.URL.Path, "/accounts/")
+	if _, err := strconv.Atoi(accNumber); err != nil {
+		writeError(w, http.StatusBadRequest, "account number must be numeric")
+		return
+	}
+
+	accountAsBytes, err := g.backend.Query(backend.AccountChaincode, "GetByNumber", accNumber)
+	if err != nil {
+		writeBackendError(w, err)
+		return
+	}
+	writeRaw(w, http.StatusOK, accountAsBytes)
+}
+
+// transfers - POST /transfers transfers money between accounts
+func (g *Gateway) transfers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	var request TransferRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid transfer: "+err.Error())
+		return
+	}
+
+	args := []string{strconv.Itoa(request.From), strconv.Itoa(request.To), strconv.Itoa(request.Amount)}
+	if request.RequestID != "" {
+		args = append(args, request.RequestID)
+	}
+
+	transferAsBytes, err := g.backend.Invoke(backend.TransferChaincode, "Money", args...)
+	if err != nil {
+		writeBackendError(w, err)
+		return
+	}
+	writeRaw(w, http.StatusCreated, transferAsBytes)
+}
+
+// cards - GET /cards?account={n} lists the cards of an account
+func (g *Gateway) cards(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	accNumber := r.URL.Query().Get("account")
+	if _, err := strconv.Atoi(accNumber); err != nil {
+		writeError(w, http.StatusBadRequest, "account query parameter must be numeric")
+		return
+	}
+
+	cardsAsBytes, err := g.backend.Query(backend.CardChaincode, "GetByAccount", accNumber)
+	if err != nil {
+		writeBackendError(w, err)
+		return
+	}
+	writeRaw(w, http.StatusOK, cardsAsBytes)
+}
+
+// writeBackendError - Maps chaincode rejections to 4xx and backend failures to 502
+func writeBackendError(w http.ResponseWriter, err error) {
+	if chaincodeErr, ok := err.(*backend.ChaincodeError); ok {
+		if strings.Contains(chaincodeErr.Message, "does not exist") {
+			writeError(w, http.StatusNotFound, chaincodeErr.Message)
+			return
+		}
+		writeError(w, http.StatusBadRequest, chaincodeErr.Message)
+		return
+	}
+	writeError(w, http.StatusBadGateway, err.Error())
+}
+
+// writeError - Writes a JSON error body
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
+
+// writeJSON - Marshals and writes a JSON body
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	bodyAsBytes, err := json.Marshal(body)
+	if err != nil {
+		writeRaw(w, http.StatusInternalServerError, []byte(`{"error":"cannot marshal response"}`))
+		return
+	}
+	writeRaw(w, status, bodyAsBytes)
+}
+
+// writeRaw - Writes an already marshaled JSON body
+func writeRaw(w http.ResponseWriter, status int, body []byte) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(body)
+}
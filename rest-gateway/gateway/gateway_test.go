@@ -0,0 +1,174 @@
+package gateway
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger-fabric-go-chaincodes/backend"
+)
+
+// newGateway - Creates a gateway over the in-memory chaincodes, holding the
+// five sample accounts created by Init
+func newGateway(t *testing.T) (*Gateway, *backend.Mock) {
+	m, err := backend.NewMock()
+	if err != nil {
+		t.Fatalf("cannot create mock backend: %v", err)
+	}
+	return New(m), m
+}
+
+// do - Serves a request and returns its response
+func do(g *Gateway, method string, path string, body string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(method, path, strings.NewReader(body))
+	recorder := httptest.NewRecorder()
+	g.ServeHTTP(recorder, request)
+	return recorder
+}
+
+// balance - Returns the balance of an account through the gateway
+func balance(t *testing.T, g *Gateway, accNumber string) int {
+	response := do(g, http.MethodGet, "/accounts/"+accNumber, "")
+	if response.Code != http.StatusOK {
+		t.Fatalf("GET /accounts/%s answered %d: %s", accNumber, response.Code, response.Body)
+	}
+	var account struct {
+		AccountBalance int `json:"accountBalance"`
+	}
+	if err := json.Unmarshal(response.Body.Bytes(), &account); err != nil {
+		t.Fatalf("GET /accounts/%s answered an invalid account: %v", accNumber, err)
+	}
+	return account.AccountBalance
+}
+
+func TestGetAccount(t *testing.T) {
+	g, _ := newGateway(t)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		status int
+	}{
+		{"existing account", http.MethodGet, "/accounts/1", http.StatusOK},
+		{"unknown account", http.MethodGet, "/accounts/99", http.StatusNotFound},
+		{"non numeric account", http.MethodGet, "/accounts/abc", http.StatusBadRequest},
+		{"wrong method", http.MethodDelete, "/accounts/1", http.StatusMethodNotAllowed},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			response := do(g, test.method, test.path, "")
+			if response.Code != test.status {
+				t.Errorf("%s %s answered %d, %d expected: %s", test.method, test.path, response.Code, test.status, response.Body)
+			}
+			if contentType := response.Header().Get("Content-Type"); contentType != "application/json" {
+				t.Errorf("%s %s answered a %s body, application/json expected", test.method, test.path, contentType)
+			}
+		})
+	}
+
+	if got := balance(t, g, "1"); got != 1000 {
+		t.Errorf("account 1 has a balance of %d, 1000 expected", got)
+	}
+}
+
+func TestCreateAccount(t *testing.T) {
+	g, m := newGateway(t)
+
+	customerAsBytes := []byte(`{"id":"C1","legalName":"Elcius Ferreira","documentId":"123456789"}`)
+	transient := map[string][]byte{"customer": customerAsBytes, "piiSalt": []byte("0123456789abcdef")}
+	if _, err := m.InvokeTransient(backend.AccountChaincode, "CreateCustomer", transient); err != nil {
+		t.Fatalf("cannot create customer: %v", err)
+	}
+
+	response := do(g, http.MethodPost, "/accounts", `{"accountNumber":10,"accountBalance":500,"customerId":"C1"}`)
+	if response.Code != http.StatusCreated {
+		t.Fatalf("POST /accounts answered %d: %s", response.Code, response.Body)
+	}
+	if location := response.Header().Get("Location"); location != "/accounts/10" {
+		t.Errorf("POST /accounts answered location %q, /accounts/10 expected", location)
+	}
+	if got := balance(t, g, "10"); got != 500 {
+		t.Errorf("account 10 has a balance of %d, 500 expected", got)
+	}
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{"existing account", `{"accountNumber":10,"accountBalance":500,"customerId":"C1"}`, http.StatusBadRequest},
+		{"unknown customer", `{"accountNumber":11,"accountBalance":500,"customerId":"C9"}`, http.StatusNotFound},
+		{"invalid body", `{"accountNumber":"eleven"}`, http.StatusBadRequest},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			response := do(g, http.MethodPost, "/accounts", test.body)
+			if response.Code != test.status {
+				t.Errorf("POST /accounts %s answered %d, %d expected: %s", test.body, response.Code, test.status, response.Body)
+			}
+		})
+	}
+}
+
+func TestTransfer(t *testing.T) {
+	g, _ := newGateway(t)
+
+	body := `{"from":1,"to":2,"amount":100,"requestId":"R1"}`
+	response := do(g, http.MethodPost, "/transfers", body)
+	if response.Code != http.StatusCreated {
+		t.Fatalf("POST /transfers answered %d: %s", response.Code, response.Body)
+	}
+	if got := balance(t, g, "1"); got != 900 {
+		t.Errorf("payer has a balance of %d after the transfer, 900 expected", got)
+	}
+	if got := balance(t, g, "2"); got != 1100 {
+		t.Errorf("receiver has a balance of %d after the transfer, 1100 expected", got)
+	}
+
+	// A retry with the same request ID returns the transfer without executing it again
+	retry := do(g, http.MethodPost, "/transfers", body)
+	if retry.Code != http.StatusCreated || retry.Body.String() != response.Body.String() {
+		t.Errorf("retry of POST /transfers answered %d: %s, the first transfer expected", retry.Code, retry.Body)
+	}
+	if got := balance(t, g, "1"); got != 900 {
+		t.Errorf("payer has a balance of %d after the retry, 900 expected", got)
+	}
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{"insufficient funds", `{"from":1,"to":2,"amount":100000}`, http.StatusBadRequest},
+		{"unknown receiver", `{"from":1,"to":99,"amount":10}`, http.StatusNotFound},
+		{"invalid body", `{"from":"one"}`, http.StatusBadRequest},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			response := do(g, http.MethodPost, "/transfers", test.body)
+			if response.Code != test.status {
+				t.Errorf("POST /transfers %s answered %d, %d expected: %s", test.body, response.Code, test.status, response.Body)
+			}
+		})
+	}
+	if got := balance(t, g, "1"); got != 900 {
+		t.Errorf("payer has a balance of %d after the rejected transfers, 900 expected", got)
+	}
+}
+
+func TestCardsRequireAccount(t *testing.T) {
+	g, _ := newGateway(t)
+
+	for _, path := range []string{"/cards", "/cards?account=abc"} {
+		response := do(g, http.MethodGet, path, "")
+		if response.Code != http.StatusBadRequest {
+			t.Errorf("GET %s answered %d, %d expected: %s", path, response.Code, http.StatusBadRequest, response.Body)
+		}
+	}
+	if response := do(g, http.MethodPost, "/cards?account=1", ""); response.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST /cards answered %d, %d expected", response.Code, http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,52 @@
+/*
+==== Run against the basic-network peer ====
+go run gatewayServer.go -config ../basic-network/connection.yaml -channel mychannel -user User1
+
+==== Run in memory (MockStub) ====
+go run gatewayServer.go -mock
+
+==== Requests ====
+curl -X POST localhost:8080/accounts -d '{"accountNumber":1,"accountBalance":1000,"customerId":"C1"}'
+curl localhost:8080/accounts/1
+curl -X POST localhost:8080/transfers -d '{"from":1,"to":2,"amount":500}'
+curl localhost:8080/cards?account=1
+*/
+
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+
+	"github.com/hyperledger-fabric-go-chaincodes/backend"
+	"github.com/hyperledger-fabric-go-chaincodes/rest-gateway/gateway"
+)
+
+func main() {
+	configPath := flag.String("config", "../basic-network/connection.yaml", "Fabric SDK connection profile")
+	channel := flag.String("channel", "mychannel", "channel of the chaincodes")
+	user := flag.String("user", "User1", "identity submitting the transactions")
+	addr := flag.String("addr", ":8080", "HTTP listen address")
+	mock := flag.Bool("mock", false, "run the chaincodes in memory instead of using the network")
+	flag.Parse()
+
+	var b backend.Backend
+	if *mock {
+		m, err := backend.NewMock()
+		if err != nil {
+			log.Fatalln(err)
+		}
+		b = m
+	} else {
+		sdk, err := backend.NewSDK(*configPath, *channel, *user)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		defer sdk.Close()
+		b = sdk
+	}
+
+	log.Println("REST gateway listening on", *addr)
+	log.Fatalln(http.ListenAndServe(*addr, gateway.New(b)))
+}
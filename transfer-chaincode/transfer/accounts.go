@@ -0,0 +1,218 @@
+package transfer
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"strconv"
+
+	"github.com/hyperledger-fabric-go-chaincodes/account-chaincode/account"
+	"github.com/hyperledger-fabric-go-chaincodes/query"
+	"github.com/hyperledger/fabric/common/util"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// accountChaincode - name of the chaincode owning the accounts
+const accountChaincode = "cc-account"
+
+// errInsufficientFunds - returned by move when the payer cannot afford a movement
+var errInsufficientFunds = errors.New("payer insufficient funds")
+
+// ledgerAccounts holds the accounts read from the account chaincode during a
+// transaction, with the product catalog ruling them. Writes of a transaction are
+// not visible to its own reads, so every movement is applied on these objects
+// and written back once by save
+type ledgerAccounts struct {
+	stub     shim.ChaincodeStubInterface
+	accounts map[int]*account.Account
+	products map[string]*account.Product
+	changed  map[int]bool
+	usages   map[int]*DailyUsage
+}
+
+// loadAccounts - Reads the given accounts and the product catalog, with one
+// cross-chaincode call each. Accounts that do not exist are absent from the result
+func loadAccounts(stub shim.ChaincodeStubInterface, accNumbers ...int) (*ledgerAccounts, error) {
+	l := &ledgerAccounts{stub: stub, accounts: make(map[int]*account.Account), products: make(map[string]*account.Product), changed: make(map[int]bool), usages: make(map[int]*DailyUsage)}
+
+	args := []string{"GetManyByNumber"}
+	for _, accNumber := range accNumbers {
+		args = append(args, strconv.Itoa(accNumber))
+	}
+
+	// If `channel` is empty, the caller's channel is assumed.
+	response := stub.InvokeChaincode(accountChaincode, util.ToChaincodeArgs(args...), "")
+	if response.Status != shim.OK {
+		return nil, errors.New("failed to invoke `" + accountChaincode + "` chaincode: " + response.Message)
+	}
+
+	var accounts []*account.Account
+	err := json.Unmarshal(response.Payload, &accounts)
+	if err != nil {
+		return nil, errors.New("cannot unmarshal accounts to JSON: " + err.Error())
+	}
+	for _, acc := range accounts {
+		if acc != nil {
+			l.accounts[acc.AccountNumber] = acc
+		}
+	}
+
+	response = stub.InvokeChaincode(accountChaincode, util.ToChaincodeArgs("GetProducts"), "")
+	if response.Status != shim.OK {
+		return nil, errors.New("failed to invoke `" + accountChaincode + "` chaincode: " + response.Message)
+	}
+
+	var products struct {
+		Records []struct {
+			Record *account.Product
+		}
+		Metadata query.Metadata
+	}
+	err = json.Unmarshal(response.Payload, &products)
+	if err != nil {
+		return nil, errors.New("cannot unmarshal products to JSON: " + err.Error())
+	}
+	if products.Metadata.Truncated {
+		return nil, errors.New("product catalog exceeds the budget of a query response")
+	}
+	for _, product := range products.Records {
+		l.products[product.Record.ID] = product.Record
+	}
+	return l, nil
+}
+
+// get - Returns a loaded account
+func (l *ledgerAccounts) get(accNumber int) (*account.Account, error) {
+	acc, found := l.accounts[accNumber]
+	if !found {
+		return nil, errors.New("account ACC" + strconv.Itoa(accNumber) + " does not exist")
+	}
+	return acc, nil
+}
+
+// product - Returns the product of a loaded account
+func (l *ledgerAccounts) product(acc *account.Account) (*account.Product, error) {
+	id := acc.Product
+	if id == "" {
+		id = account.DefaultProduct
+	}
+	product, found := l.products[id]
+	if !found {
+		return nil, errors.New("product " + id + " of account ACC" + strconv.Itoa(acc.AccountNumber) + " does not exist")
+	}
+	return product, nil
+}
+
+// allow - Checks the product of a loaded account allows an operation
+func (l *ledgerAccounts) allow(acc *account.Account, operation string) error {
+	product, err := l.product(acc)
+	if err != nil {
+		return err
+	}
+	if !product.Allows(operation) {
+		return errors.New("product " + product.ID + " of account ACC" + strconv.Itoa(acc.AccountNumber) + " does not allow " + operation)
+	}
+	return nil
+}
+
+// tier - Returns the transfer limits tier of a loaded account
+func (l *ledgerAccounts) tier(acc *account.Account) (string, error) {
+	if acc.Tier != "" {
+		return acc.Tier, nil
+	}
+	product, err := l.product(acc)
+	if err != nil {
+		return "", err
+	}
+	return product.Tier, nil
+}
+
+// move - Debits payer and credits receiver, checking their products and the payer funds
+func (l *ledgerAccounts) move(payerAccNumber int, receiverAccNumber int, value int) error {
+	payerAcc, err := l.get(payerAccNumber)
+	if err != nil {
+		return err
+	}
+	receiverAcc, err := l.get(receiverAccNumber)
+	if err != nil {
+		return err
+	}
+	if err = l.allow(payerAcc, account.OpDebit); err != nil {
+		return err
+	}
+	if err = l.allow(receiverAcc, account.OpCredit); err != nil {
+		return err
+	}
+	if payerAcc.AvailableBalance() < value {
+		return errInsufficientFunds
+	}
+
+	payerAcc.AccountBalance -= value
+	receiverAcc.AccountBalance += value
+	l.changed[payerAccNumber] = true
+	l.changed[receiverAccNumber] = true
+	return nil
+}
+
+// hold - Locks part of the available balance of an account
+func (l *ledgerAccounts) hold(accNumber int, value int) error {
+	acc, err := l.get(accNumber)
+	if err != nil {
+		return err
+	}
+	if err = l.allow(acc, account.OpHold); err != nil {
+		return err
+	}
+	if acc.AvailableBalance() < value {
+		return errInsufficientFunds
+	}
+
+	acc.HeldBalance += value
+	l.changed[accNumber] = true
+	return nil
+}
+
+// unhold - Unlocks a previously held amount, making it available again
+func (l *ledgerAccounts) unhold(accNumber int, value int) error {
+	acc, err := l.get(accNumber)
+	if err != nil {
+		return err
+	}
+	if acc.HeldBalance < value {
+		return errors.New("account ACC" + strconv.Itoa(accNumber) + " holds less than " + strconv.Itoa(value))
+	}
+
+	acc.HeldBalance -= value
+	l.changed[accNumber] = true
+	return nil
+}
+
+// save - Writes the changed accounts back with a single cross-chaincode call
+func (l *ledgerAccounts) save() error {
+	if len(l.changed) == 0 {
+		return nil
+	}
+
+	// Sorted to keep the call deterministic across endorsers
+	var accNumbers []int
+	for accNumber := range l.changed {
+		accNumbers = append(accNumbers, accNumber)
+	}
+	sort.Ints(accNumbers)
+
+	var accounts []*account.Account
+	for _, accNumber := range accNumbers {
+		accounts = append(accounts, l.accounts[accNumber])
+	}
+	accountsAsBytes, err := json.Marshal(accounts)
+	if err != nil {
+		return errors.New("failed to marshal account objects: " + err.Error())
+	}
+
+	response := l.stub.InvokeChaincode(accountChaincode, util.ToChaincodeArgs("UpdateMany", string(accountsAsBytes)), "")
+	if response.Status != shim.OK {
+		return errors.New("could not update accounts: " + response.Message)
+	}
+	l.changed = make(map[int]bool)
+	return nil
+}
@@ -0,0 +1,379 @@
+package transfer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/hyperledger-fabric-go-chaincodes/account-chaincode/account"
+	"github.com/hyperledger-fabric-go-chaincodes/auth"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/peer"
+)
+
+// approvalPolicyKey - state key holding the approval policy
+const approvalPolicyKey = "CFG_APPROVAL"
+
+// Pending transfer status
+const (
+	pendingApproval = "pending"
+	pendingExecuted = "executed"
+	pendingRejected = "rejected"
+	pendingFailed   = "failed"
+)
+
+// ApprovalPolicy structure. Transfers with a value above Threshold need Quorum
+// approvals from distinct approvers. A zero Threshold disables approvals
+type ApprovalPolicy struct {
+	Threshold int `json:"threshold"`
+	Quorum    int `json:"quorum"`
+}
+
+// Decision structure with the approval or rejection of an approver, or the
+// signature of an owner of the payer account, identified by CustomerID
+type Decision struct {
+	ApproverID string `json:"approverId"`
+	MSPID      string `json:"mspId"`
+	TxID       string `json:"txId"`
+	CustomerID string `json:"customerId,omitempty"`
+}
+
+// PendingTransfer structure of a transfer waiting for approvals, or for the
+// signatures of every owner of a joint payer account. It is stored as PND<id>,
+// where id is the ID of the Money transaction, which is also the ID of the
+// transfer once executed
+type PendingTransfer struct {
+	ObjectType            string     `json:"docType"`
+	ID                    string     `json:"id"`
+	RequestID             string     `json:"requestId,omitempty"`
+	PayerAccountNumber    int        `json:"payerAccountNumber"`
+	ReceiverAccountNumber int        `json:"receiverAccountNumber"`
+	Value                 int        `json:"value"`
+	CreatorID             string     `json:"creatorId"`
+	Quorum                int        `json:"quorum"`
+	Approvals             []Decision `json:"approvals"`
+	RequiredSigners       []string   `json:"requiredSigners,omitempty"`
+	Signatures            []Decision `json:"signatures,omitempty"`
+	Rejection             *Decision  `json:"rejection,omitempty"`
+	Status                string     `json:"status"`
+	Reason                string     `json:"reason,omitempty"`
+}
+
+// SetApprovalPolicy - Sets the threshold above which transfers need approvals
+// and the number of approvals needed. Restricted to admins
+// param: Threshold, Quorum
+func SetApprovalPolicy(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	fmt.Println("[DEBUG] begin transfer.SetApprovalPolicy")
+
+	err := auth.Require(stub, auth.Admin)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// Input sanitation
+	if len(args) != 2 {
+		return shim.Error("incorrect number of arguments. 2 expected")
+	}
+	threshold, err := strconv.Atoi(args[0])
+	if err != nil || threshold < 0 {
+		return shim.Error("1st argument must be a non-negative numeric string")
+	}
+	quorum, err := strconv.Atoi(args[1])
+	if err != nil || quorum < 1 {
+		return shim.Error("2nd argument must be a positive numeric string")
+	}
+
+	policyAsBytes, err := json.Marshal(ApprovalPolicy{Threshold: threshold, Quorum: quorum})
+	if err != nil {
+		return shim.Error("failed to marshal approval policy: " + err.Error())
+	}
+	err = stub.PutState(approvalPolicyKey, policyAsBytes)
+	if err != nil {
+		return shim.Error("failed to put state of approval policy: " + err.Error())
+	}
+	err = stub.SetEvent("approval_policy_updated", policyAsBytes)
+	if err != nil {
+		return shim.Error("failed to set event `approval_policy_updated`: " + err.Error())
+	}
+
+	fmt.Println("[DEBUG] end transfer.SetApprovalPolicy")
+	return shim.Success(policyAsBytes)
+}
+
+// Approve - Approves a pending transfer, executing it once the quorum is
+// reached. If the payer cannot afford it by then, it is recorded as failed.
+// Restricted to approvers other than the transfer creator
+// param: PendingTransferID
+func Approve(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	fmt.Println("[DEBUG] begin transfer.Approve")
+
+	pending, decision, err := decide(stub, args)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	for _, approval := range pending.Approvals {
+		if approval.ApproverID == decision.ApproverID {
+			return shim.Error("pending transfer " + pending.ID + " was already approved by this identity")
+		}
+	}
+	pending.Approvals = append(pending.Approvals, *decision)
+
+	// Execute once the quorum is reached, and every owner signed if needed
+	eventName := "transfer_approved"
+	if pending.ready() {
+		eventName, err = executePending(stub, pending)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+
+	pendingAsBytes, err := putPendingTransfer(stub, pending)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// Executed transfers already notified listeners with `money_transferred`
+	if eventName != "" {
+		err = stub.SetEvent(eventName, pendingAsBytes)
+		if err != nil {
+			return shim.Error("failed to set event `" + eventName + "`: " + err.Error())
+		}
+	}
+
+	fmt.Println("[DEBUG] end transfer.Approve")
+	return shim.Success(pendingAsBytes)
+}
+
+// Reject - Rejects a pending transfer. A single rejection cancels it.
+// Restricted to approvers other than the transfer creator
+// param: PendingTransferID
+func Reject(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	fmt.Println("[DEBUG] begin transfer.Reject")
+
+	pending, decision, err := decide(stub, args)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	pending.Rejection, pending.Status = decision, pendingRejected
+
+	pendingAsBytes, err := putPendingTransfer(stub, pending)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	err = stub.SetEvent("transfer_rejected", pendingAsBytes)
+	if err != nil {
+		return shim.Error("failed to set event `transfer_rejected`: " + err.Error())
+	}
+
+	fmt.Println("[DEBUG] end transfer.Reject")
+	return shim.Success(pendingAsBytes)
+}
+
+// GetPendingTransfer - Queries a pending transfer by its ID
+// param: PendingTransferID
+func GetPendingTransfer(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	fmt.Println("[DEBUG] begin transfer.GetPendingTransfer")
+
+	// Input sanitation
+	if len(args) != 1 {
+		return shim.Error("incorrect number of arguments. 1 expected")
+	}
+
+	pendingAsBytes, err := stub.GetState("PND" + args[0])
+	if err != nil {
+		return shim.Error("failed to get state of pending transfer " + args[0] + ": " + err.Error())
+	} else if pendingAsBytes == nil {
+		return shim.Error("pending transfer " + args[0] + " does not exist")
+	}
+
+	fmt.Println("[DEBUG] end transfer.GetPendingTransfer")
+	return shim.Success(pendingAsBytes)
+}
+
+// ready - Reports whether a pending transfer has every approval and signature it needs
+func (p *PendingTransfer) ready() bool {
+	if len(p.Approvals) < p.Quorum {
+		return false
+	}
+	for _, customerID := range p.RequiredSigners {
+		if !p.signed(customerID) {
+			return false
+		}
+	}
+	return true
+}
+
+// signed - Reports whether an owner of the payer account signed a pending transfer
+func (p *PendingTransfer) signed(customerID string) bool {
+	for _, signature := range p.Signatures {
+		if signature.CustomerID == customerID {
+			return true
+		}
+	}
+	return false
+}
+
+// executePending - Executes a pending transfer that is ready. If the payer
+// cannot afford it, it is recorded as failed. Returns the event to emit, none
+// when executed since the transfer notifies listeners
+func executePending(stub shim.ChaincodeStubInterface, pending *PendingTransfer) (string, error) {
+	transfer := &Transfer{
+		ObjectType:            "Transfer",
+		TxID:                  pending.ID,
+		RequestID:             pending.RequestID,
+		PayerAccountNumber:    pending.PayerAccountNumber,
+		ReceiverAccountNumber: pending.ReceiverAccountNumber,
+		Value:                 pending.Value,
+	}
+	_, err := executeTransfer(stub, transfer)
+	if err == errInsufficientFunds {
+		pending.Status, pending.Reason = pendingFailed, err.Error()
+		return "transfer_failed", nil
+	} else if err != nil {
+		return "", err
+	}
+	pending.Status = pendingExecuted
+	return "", nil
+}
+
+// requiresApproval - Reports whether a transfer value is above the threshold
+func (p *ApprovalPolicy) requiresApproval(value int) bool {
+	return p.Threshold > 0 && value > p.Threshold
+}
+
+// requireNoApproval - Refuses a value needing approvals, for the transfers that
+// cannot wait for them
+func (p *ApprovalPolicy) requireNoApproval(value int) error {
+	if p.requiresApproval(value) {
+		return fmt.Errorf("value %d is above the approval threshold of %d, transfer it with Money to get it approved", value, p.Threshold)
+	}
+	return nil
+}
+
+// getApprovalPolicy - Reads the approval policy. Approvals are disabled when none was set
+func getApprovalPolicy(stub shim.ChaincodeStubInterface) (*ApprovalPolicy, error) {
+	policy := &ApprovalPolicy{}
+
+	policyAsBytes, err := stub.GetState(approvalPolicyKey)
+	if err != nil {
+		return nil, errors.New("failed to get state of approval policy: " + err.Error())
+	} else if policyAsBytes == nil {
+		return policy, nil
+	}
+
+	err = json.Unmarshal(policyAsBytes, policy)
+	if err != nil {
+		return nil, errors.New("cannot unmarshal approval policy to JSON: " + err.Error())
+	}
+	return policy, nil
+}
+
+// createPendingTransfer - Records a transfer waiting for a quorum of approvals
+// and the signatures of the required signers. The creator signs it when it is
+// one of them
+func createPendingTransfer(stub shim.ChaincodeStubInterface, transfer *Transfer, quorum int, signer *account.Signer, requiredSigners []string) ([]byte, error) {
+	creator, err := newDecision(stub)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check the accounts exist, funds are checked on execution
+	accounts, err := loadAccounts(stub, transfer.PayerAccountNumber, transfer.ReceiverAccountNumber)
+	if err != nil {
+		return nil, err
+	}
+	for _, accNumber := range []int{transfer.PayerAccountNumber, transfer.ReceiverAccountNumber} {
+		if _, err := accounts.get(accNumber); err != nil {
+			return nil, err
+		}
+	}
+
+	pending := &PendingTransfer{
+		ObjectType:            "PendingTransfer",
+		ID:                    transfer.TxID,
+		RequestID:             transfer.RequestID,
+		PayerAccountNumber:    transfer.PayerAccountNumber,
+		ReceiverAccountNumber: transfer.ReceiverAccountNumber,
+		Value:                 transfer.Value,
+		CreatorID:             creator.ApproverID,
+		Quorum:                quorum,
+		Approvals:             []Decision{},
+		RequiredSigners:       requiredSigners,
+		Status:                pendingApproval,
+	}
+	if contains(requiredSigners, signer.CustomerID) {
+		creator.CustomerID = signer.CustomerID
+		pending.Signatures = append(pending.Signatures, *creator)
+	}
+	pendingAsBytes, err := putPendingTransfer(stub, pending)
+	if err != nil {
+		return nil, err
+	}
+	err = stub.SetEvent("transfer_pending", pendingAsBytes)
+	if err != nil {
+		return nil, errors.New("failed to set event `transfer_pending`: " + err.Error())
+	}
+	return pendingAsBytes, nil
+}
+
+// decide - Checks the caller may decide on a pending transfer and returns it
+// with the caller decision
+func decide(stub shim.ChaincodeStubInterface, args []string) (*PendingTransfer, *Decision, error) {
+	// Input sanitation
+	if len(args) != 1 {
+		return nil, nil, errors.New("incorrect number of arguments. 1 expected")
+	}
+
+	err := auth.Require(stub, auth.Approver)
+	if err != nil {
+		return nil, nil, err
+	}
+	decision, err := newDecision(stub)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pending, err := getPendingTransfer(stub, args[0])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if pending.Status != pendingApproval {
+		return nil, nil, errors.New("pending transfer " + pending.ID + " is already " + pending.Status)
+	}
+	if pending.CreatorID == decision.ApproverID {
+		return nil, nil, errors.New("the creator of a transfer cannot decide on it")
+	}
+	return pending, decision, nil
+}
+
+// getPendingTransfer - Reads a pending transfer by its ID
+func getPendingTransfer(stub shim.ChaincodeStubInterface, id string) (*PendingTransfer, error) {
+	pendingAsBytes, err := stub.GetState("PND" + id)
+	if err != nil {
+		return nil, errors.New("failed to get state of pending transfer " + id + ": " + err.Error())
+	} else if pendingAsBytes == nil {
+		return nil, errors.New("pending transfer " + id + " does not exist")
+	}
+
+	pending := &PendingTransfer{}
+	err = json.Unmarshal(pendingAsBytes, pending)
+	if err != nil {
+		return nil, errors.New("cannot unmarshal pending transfer to JSON: " + err.Error())
+	}
+	return pending, nil
+}
+
+// putPendingTransfer - Writes a pending transfer to the ledger and returns it as JSON
+func putPendingTransfer(stub shim.ChaincodeStubInterface, pending *PendingTransfer) ([]byte, error) {
+	pendingAsBytes, err := json.Marshal(pending)
+	if err != nil {
+		return nil, errors.New("failed to marshal pending transfer object: " + err.Error())
+	}
+	err = stub.PutState("PND"+pending.ID, pendingAsBytes)
+	if err != nil {
+		return nil, errors.New("failed to put state of pending transfer: " + err.Error())
+	}
+	return pendingAsBytes, nil
+}
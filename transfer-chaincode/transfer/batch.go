@@ -0,0 +1,197 @@
+package transfer
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/peer"
+)
+
+// maxInt - largest int, bounding the total of a batch
+const maxInt = int(^uint(0) >> 1)
+
+// Batch line status
+const (
+	lineTransferred = "transferred"
+	lineRejected    = "rejected"
+	lineNotExecuted = "not executed"
+)
+
+// BatchLine structure with one credit of a batch transfer and its outcome
+type BatchLine struct {
+	Line                  int    `json:"line"`
+	ReceiverAccountNumber int    `json:"receiverAccountNumber"`
+	Value                 int    `json:"value"`
+	Status                string `json:"status"`
+	Reason                string `json:"reason,omitempty"`
+}
+
+// Batch structure with 6 properties. It is stored as BAT<txId>, is the payload
+// of the `batch_transferred` event and is returned as per-line result summary
+type Batch struct {
+	ObjectType         string      `json:"docType"`
+	TxID               string      `json:"txId"`
+	PayerAccountNumber int         `json:"payerAccountNumber"`
+	Total              int         `json:"total"`
+	Executed           bool        `json:"executed"`
+	Lines              []BatchLine `json:"lines"`
+}
+
+// BatchTransfer - Transfers money from one account to many (e.g. payroll) in a
+// single transaction. Funds are checked once against the total and either every
+// line is credited or none is. Accounts are read and written back with one
+// cross-chaincode call each. Lines above the approval threshold are rejected.
+// When rejected, the error message holds the summary
+// param: AccountNumber, (AccountNumber, Value)...
+func BatchTransfer(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	fmt.Println("[DEBUG] begin transfer.BatchTransfer")
+
+	// Input sanitation
+	if len(args) < 3 || len(args)%2 == 0 {
+		return shim.Error("incorrect number of arguments. payer followed by receiver and value pairs expected")
+	}
+	payerAccNumber, err := strconv.Atoi(args[0])
+	if err != nil {
+		return shim.Error("1st argument must be a numeric string")
+	}
+
+	batch := &Batch{ObjectType: "Batch", TxID: stub.GetTxID(), PayerAccountNumber: payerAccNumber}
+	rejected := false
+
+	// Lines cannot wait for approvals, so none may need them
+	policy, err := getApprovalPolicy(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// Mapping pairs to lines
+	for i := 1; i < len(args); i += 2 {
+		line := BatchLine{Line: len(batch.Lines) + 1, Status: lineNotExecuted}
+		receiverAccNumber, errReceiver := strconv.Atoi(args[i])
+		value, errValue := strconv.Atoi(args[i+1])
+		switch {
+		case errReceiver != nil:
+			line.Status, line.Reason = lineRejected, "receiver must be a numeric string"
+		case errValue != nil:
+			line.Status, line.Reason = lineRejected, "value must be a numeric string"
+		case value <= 0:
+			line.Status, line.Reason = lineRejected, "value must be positive"
+		case value > maxInt-batch.Total:
+			line.Status, line.Reason = lineRejected, "total of the batch is too large"
+		case receiverAccNumber == payerAccNumber:
+			line.Status, line.Reason = lineRejected, "the transfer must be between different accounts"
+		case policy.requiresApproval(value):
+			line.Status, line.Reason = lineRejected, policy.requireNoApproval(value).Error()
+		}
+		line.ReceiverAccountNumber, line.Value = receiverAccNumber, value
+		if line.Status != lineRejected {
+			batch.Total += value
+		}
+		rejected = rejected || line.Status == lineRejected
+		batch.Lines = append(batch.Lines, line)
+	}
+	if rejected {
+		return batchRejected(batch)
+	}
+
+	// Check the caller may debit the payer account
+	err = authorizeSingleDebit(stub, payerAccNumber)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// Get payer and every receiver at once
+	accNumbers := []int{payerAccNumber}
+	for _, line := range batch.Lines {
+		accNumbers = append(accNumbers, line.ReceiverAccountNumber)
+	}
+	accounts, err := loadAccounts(stub, accNumbers...)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	payerAcc, err := accounts.get(payerAccNumber)
+	if err != nil {
+		return shim.Error("payer " + err.Error())
+	}
+
+	// Check receivers and payer funds against the total
+	for i := range batch.Lines {
+		if _, err := accounts.get(batch.Lines[i].ReceiverAccountNumber); err != nil {
+			batch.Lines[i].Status, batch.Lines[i].Reason = lineRejected, "receiver account does not exist"
+			rejected = true
+		}
+	}
+	if !rejected && payerAcc.AvailableBalance() < batch.Total {
+		fmt.Println("[DEBUG] insufficient funds. payerAcc.AvailableBalance() = " + strconv.Itoa(payerAcc.AvailableBalance()))
+		for i := range batch.Lines {
+			batch.Lines[i].Status, batch.Lines[i].Reason = lineRejected, errInsufficientFunds.Error()
+		}
+		rejected = true
+	}
+	var usage *DailyUsage
+	if !rejected {
+		values := make([]int, len(batch.Lines))
+		for i := range batch.Lines {
+			values[i] = batch.Lines[i].Value
+		}
+		usage, err = checkLimits(stub, accounts, payerAccNumber, values...)
+		if err != nil {
+			for i := range batch.Lines {
+				batch.Lines[i].Status, batch.Lines[i].Reason = lineRejected, err.Error()
+			}
+			rejected = true
+		}
+	}
+	if rejected {
+		return batchRejected(batch)
+	}
+
+	// Transfer money. Funds were checked against the total, so no line can fail
+	for i := range batch.Lines {
+		err = accounts.move(payerAccNumber, batch.Lines[i].ReceiverAccountNumber, batch.Lines[i].Value)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		batch.Lines[i].Status = lineTransferred
+	}
+	batch.Executed = true
+	err = spend(stub, accounts, usage)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// Update every Account at once
+	err = accounts.save()
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// Record the batch and notify listeners
+	batchAsBytes, err := json.Marshal(batch)
+	if err != nil {
+		return shim.Error("failed to marshal batch object: " + err.Error())
+	}
+	err = stub.PutState("BAT"+batch.TxID, batchAsBytes)
+	if err != nil {
+		return shim.Error("failed to put state of batch: " + err.Error())
+	}
+	err = stub.SetEvent("batch_transferred", batchAsBytes)
+	if err != nil {
+		return shim.Error("failed to set event `batch_transferred`: " + err.Error())
+	}
+
+	fmt.Println("[DEBUG] end transfer.BatchTransfer")
+	return shim.Success(batchAsBytes)
+}
+
+// batchRejected - Fails the whole batch, with the per-line summary as message
+func batchRejected(batch *Batch) peer.Response {
+	batchAsBytes, err := json.Marshal(batch)
+	if err != nil {
+		return shim.Error("batch rejected")
+	}
+	fmt.Println("[DEBUG] batch rejected: " + string(batchAsBytes))
+	return shim.Error("batch rejected: " + string(batchAsBytes))
+}
@@ -0,0 +1,77 @@
+package transfer
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/peer"
+)
+
+// TransferController struct
+type TransferController struct {
+}
+
+// Init - initializes chaincode
+func (t *TransferController) Init(stub shim.ChaincodeStubInterface) peer.Response {
+	return shim.Success(nil)
+}
+
+// Invoke - Entry point for Invocations
+func (t *TransferController) Invoke(stub shim.ChaincodeStubInterface) peer.Response {
+	function, args := stub.GetFunctionAndParameters()
+	fmt.Println("[DEBUG] Transfer chaincode invoking " + function + " function")
+
+	// Handle different functions
+	switch function {
+	case "Money":
+		return Money(stub, args)
+	case "BatchTransfer":
+		return BatchTransfer(stub, args)
+	case "CreateSchedule":
+		return CreateSchedule(stub, args)
+	case "CancelSchedule":
+		return CancelSchedule(stub, args)
+	case "ExecuteDue":
+		return ExecuteDue(stub)
+	case "Hold":
+		return Hold(stub, args)
+	case "Release":
+		return Release(stub, args)
+	case "Refund":
+		return Refund(stub, args)
+	case "Reverse":
+		return Reverse(stub, args)
+	case "SetApprovalPolicy":
+		return SetApprovalPolicy(stub, args)
+	case "SetFeeSchedule":
+		return SetFeeSchedule(stub, args)
+	case "SetTransferLimits":
+		return SetTransferLimits(stub, args)
+	case "Approve":
+		return Approve(stub, args)
+	case "Reject":
+		return Reject(stub, args)
+	case "Sign":
+		return Sign(stub, args)
+	case "GetTransfer":
+		return GetTransfer(stub, args)
+	case "GetByRequestID":
+		return GetByRequestID(stub, args)
+	case "GetSchedule":
+		return GetSchedule(stub, args)
+	case "GetExecutions":
+		return GetExecutions(stub, args)
+	case "GetEscrow":
+		return GetEscrow(stub, args)
+	case "GetPendingTransfer":
+		return GetPendingTransfer(stub, args)
+	case "GetFeeSchedule":
+		return GetFeeSchedule(stub)
+	case "GetTransferLimits":
+		return GetTransferLimits(stub)
+	case "GetDailyUsage":
+		return GetDailyUsage(stub, args)
+	default:
+		return shim.Error("received unknown function invocation on transfer chaincode")
+	}
+}
@@ -0,0 +1,308 @@
+package transfer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/hyperledger-fabric-go-chaincodes/auth"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/peer"
+)
+
+// Escrow status
+const (
+	escrowHeld     = "held"
+	escrowReleased = "released"
+	escrowRefunded = "refunded"
+)
+
+// Escrow structure of a conditional transfer. It is stored as ESC<id>, where id
+// is the ID of the Hold transaction. While held, the value stays in the payer
+// balance but is counted in its held balance, so it cannot be spent
+type Escrow struct {
+	ObjectType            string `json:"docType"`
+	ID                    string `json:"id"`
+	PayerAccountNumber    int    `json:"payerAccountNumber"`
+	ReceiverAccountNumber int    `json:"receiverAccountNumber"`
+	Value                 int    `json:"value"`
+	ExpiryDate            string `json:"expiryDate,omitempty"`
+	Status                string `json:"status"`
+	SettlementTxID        string `json:"settlementTxId,omitempty"`
+	Reason                string `json:"reason,omitempty"`
+}
+
+// Hold - Locks funds of the payer in escrow for a receiver. When an expiry date
+// is given, the escrow is refunded by the first Release or Refund after it.
+// Values above the approval threshold are refused
+// param: AccountNumber, AccountNumber, Value, [ExpiryDate]
+func Hold(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	fmt.Println("[DEBUG] begin transfer.Hold")
+
+	// Input sanitation
+	if len(args) != 3 && len(args) != 4 {
+		return shim.Error("incorrect number of arguments. 3 or 4 expected")
+	}
+	payerAccNumber, err := strconv.Atoi(args[0])
+	if err != nil {
+		return shim.Error("1st argument must be a numeric string")
+	}
+	receiverAccNumber, err := strconv.Atoi(args[1])
+	if err != nil {
+		return shim.Error("2nd argument must be a numeric string")
+	}
+	if payerAccNumber == receiverAccNumber {
+		return shim.Error("the transfer must be between different accounts")
+	}
+	value, err := strconv.Atoi(args[2])
+	if err != nil || value <= 0 {
+		return shim.Error("3rd argument must be a positive numeric string")
+	}
+
+	escrow := &Escrow{
+		ObjectType:            "Escrow",
+		ID:                    stub.GetTxID(),
+		PayerAccountNumber:    payerAccNumber,
+		ReceiverAccountNumber: receiverAccNumber,
+		Value:                 value,
+		Status:                escrowHeld,
+	}
+	if len(args) == 4 && args[3] != "" {
+		expiryDate, err := parseDate(args[3])
+		if err != nil {
+			return shim.Error("4th argument must be a date: " + err.Error())
+		}
+		escrow.ExpiryDate = formatDate(expiryDate)
+	}
+
+	// Check the caller may debit the payer account, without approvals
+	err = authorizeSingleDebit(stub, payerAccNumber)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	policy, err := getApprovalPolicy(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	err = policy.requireNoApproval(value)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// Lock payer funds, checking the receiver exists
+	accounts, err := loadAccounts(stub, payerAccNumber, receiverAccNumber)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	_, err = accounts.get(receiverAccNumber)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	// The held value counts against the payer limits, whatever the settlement
+	usage, err := checkLimits(stub, accounts, payerAccNumber, value)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	err = accounts.hold(payerAccNumber, value)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	err = spend(stub, accounts, usage)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	err = accounts.save()
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	escrowAsBytes, err := putEscrow(stub, escrow)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	err = stub.SetEvent("escrow_held", escrowAsBytes)
+	if err != nil {
+		return shim.Error("failed to set event `escrow_held`: " + err.Error())
+	}
+
+	fmt.Println("[DEBUG] end transfer.Hold")
+	return shim.Success(escrowAsBytes)
+}
+
+// Release - Sends the escrow funds to the receiver. Restricted to the payer and
+// arbiters. An expired escrow is refunded instead, whoever the caller, which the
+// returned escrow status reports
+// param: EscrowID
+func Release(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	fmt.Println("[DEBUG] begin transfer.Release")
+	response := settleEscrow(stub, args, escrowReleased)
+	fmt.Println("[DEBUG] end transfer.Release")
+	return response
+}
+
+// Refund - Returns the escrow funds to the payer. Restricted to the receiver,
+// arbiters and admins until the escrow expires
+// param: EscrowID
+func Refund(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	fmt.Println("[DEBUG] begin transfer.Refund")
+	response := settleEscrow(stub, args, escrowRefunded)
+	fmt.Println("[DEBUG] end transfer.Refund")
+	return response
+}
+
+// GetEscrow - Queries an escrow by its ID
+// param: EscrowID
+func GetEscrow(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	fmt.Println("[DEBUG] begin transfer.GetEscrow")
+
+	// Input sanitation
+	if len(args) != 1 {
+		return shim.Error("incorrect number of arguments. 1 expected")
+	}
+
+	escrowAsBytes, err := stub.GetState("ESC" + args[0])
+	if err != nil {
+		return shim.Error("failed to get state of escrow " + args[0] + ": " + err.Error())
+	} else if escrowAsBytes == nil {
+		return shim.Error("escrow " + args[0] + " does not exist")
+	}
+
+	fmt.Println("[DEBUG] end transfer.GetEscrow")
+	return shim.Success(escrowAsBytes)
+}
+
+// settleEscrow - Releases or refunds a held escrow. Expired escrows are always refunded
+func settleEscrow(stub shim.ChaincodeStubInterface, args []string, outcome string) peer.Response {
+	// Input sanitation
+	if len(args) != 1 {
+		return shim.Error("incorrect number of arguments. 1 expected")
+	}
+
+	escrow, err := getEscrow(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if escrow.Status != escrowHeld {
+		return shim.Error("escrow " + escrow.ID + " is already " + escrow.Status)
+	}
+
+	// Refund automatically once expired, whoever the caller
+	if escrow.ExpiryDate != "" {
+		now, err := txTime(stub)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		if formatDate(now) >= escrow.ExpiryDate {
+			outcome, escrow.Reason = escrowRefunded, "expired"
+		}
+	}
+	if escrow.Reason != "expired" {
+		err = authorizeSettlement(stub, escrow, outcome)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+
+	// The approval threshold may have been lowered since the hold
+	if outcome == escrowReleased {
+		policy, err := getApprovalPolicy(stub)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		err = policy.requireNoApproval(escrow.Value)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+
+	accounts, err := loadAccounts(stub, escrow.PayerAccountNumber, escrow.ReceiverAccountNumber)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	err = accounts.unhold(escrow.PayerAccountNumber, escrow.Value)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if outcome == escrowReleased {
+		err = accounts.move(escrow.PayerAccountNumber, escrow.ReceiverAccountNumber, escrow.Value)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+	err = accounts.save()
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	escrow.Status, escrow.SettlementTxID = outcome, stub.GetTxID()
+	escrowAsBytes, err := putEscrow(stub, escrow)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	err = stub.SetEvent("escrow_"+outcome, escrowAsBytes)
+	if err != nil {
+		return shim.Error("failed to set event `escrow_" + outcome + "`: " + err.Error())
+	}
+
+	return shim.Success(escrowAsBytes)
+}
+
+// authorizeSettlement - Checks the caller may settle an escrow before its
+// expiry. Arbiters may release or refund it; otherwise only the payer (or an
+// operator acting for it) may release it, and only the receiver or an admin
+// may refund it
+func authorizeSettlement(stub shim.ChaincodeStubInterface, escrow *Escrow, outcome string) error {
+	if auth.Require(stub, auth.Arbiter) == nil {
+		return nil
+	}
+	if outcome == escrowReleased {
+		err := authorizeSingleDebit(stub, escrow.PayerAccountNumber)
+		if err != nil {
+			return errors.New("only the payer or an arbiter may release escrow " + escrow.ID + ": " + err.Error())
+		}
+		return nil
+	}
+
+	if auth.Require(stub, auth.Admin) == nil {
+		return nil
+	}
+	signer, err := getSigner(stub, escrow.ReceiverAccountNumber)
+	if err != nil {
+		return err
+	}
+	if signer.CustomerID == "" {
+		return errors.New("only the receiver, an arbiter or an admin may refund escrow " + escrow.ID + " before its expiry")
+	}
+	return nil
+}
+
+// getEscrow - Reads an escrow from the ledger
+func getEscrow(stub shim.ChaincodeStubInterface, id string) (*Escrow, error) {
+	escrowAsBytes, err := stub.GetState("ESC" + id)
+	if err != nil {
+		return nil, errors.New("failed to get state of escrow " + id + ": " + err.Error())
+	} else if escrowAsBytes == nil {
+		return nil, errors.New("escrow " + id + " does not exist")
+	}
+
+	escrow := &Escrow{}
+	err = json.Unmarshal(escrowAsBytes, escrow)
+	if err != nil {
+		return nil, errors.New("cannot unmarshal escrow to JSON: " + err.Error())
+	}
+	return escrow, nil
+}
+
+// putEscrow - Writes an escrow to the ledger and returns it as JSON
+func putEscrow(stub shim.ChaincodeStubInterface, escrow *Escrow) ([]byte, error) {
+	escrowAsBytes, err := json.Marshal(escrow)
+	if err != nil {
+		return nil, errors.New("failed to marshal escrow object: " + err.Error())
+	}
+	err = stub.PutState("ESC"+escrow.ID, escrowAsBytes)
+	if err != nil {
+		return nil, errors.New("failed to put state of escrow: " + err.Error())
+	}
+	return escrowAsBytes, nil
+}
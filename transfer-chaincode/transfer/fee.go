@@ -0,0 +1,183 @@
+package transfer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/hyperledger-fabric-go-chaincodes/auth"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/peer"
+)
+
+// feeScheduleKey - state key holding the fee schedule
+const feeScheduleKey = "CFG_FEES"
+
+// Fee types
+const (
+	feeFlat       = "flat"
+	feePercentage = "percentage"
+	feeTiered     = "tiered"
+)
+
+// FeeTier structure with the fee of transfers up to a value (0 means no upper bound)
+type FeeTier struct {
+	UpTo        int `json:"upTo"`
+	Flat        int `json:"flat"`
+	BasisPoints int `json:"basisPoints"`
+}
+
+// FeeSchedule structure with the fee charged to the payer of a transfer and
+// credited to FeeAccountNumber. Percentages are in basis points (1/100 of a
+// percent) and rounded down, so fees are computed with integer arithmetic only.
+// The fee is then bounded by Min and Max (0 means no maximum)
+type FeeSchedule struct {
+	FeeAccountNumber int       `json:"feeAccountNumber"`
+	Type             string    `json:"type"`
+	Flat             int       `json:"flat,omitempty"`
+	BasisPoints      int       `json:"basisPoints,omitempty"`
+	Tiers            []FeeTier `json:"tiers,omitempty"`
+	Min              int       `json:"min,omitempty"`
+	Max              int       `json:"max,omitempty"`
+}
+
+// SetFeeSchedule - Replaces the fee schedule. Restricted to admins
+// param: FeeSchedule JSON
+func SetFeeSchedule(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	fmt.Println("[DEBUG] begin transfer.SetFeeSchedule")
+
+	err := auth.Require(stub, auth.Admin)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// Input sanitation
+	if len(args) != 1 {
+		return shim.Error("incorrect number of arguments. 1 expected")
+	}
+	var schedule FeeSchedule
+	err = json.Unmarshal([]byte(args[0]), &schedule)
+	if err != nil {
+		return shim.Error("fee schedule not valid as json object: " + err.Error())
+	}
+	err = schedule.validate()
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// Check the fee account exists
+	accounts, err := loadAccounts(stub, schedule.FeeAccountNumber)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	_, err = accounts.get(schedule.FeeAccountNumber)
+	if err != nil {
+		return shim.Error("fee " + err.Error())
+	}
+
+	scheduleAsBytes, err := json.Marshal(schedule)
+	if err != nil {
+		return shim.Error("failed to marshal fee schedule: " + err.Error())
+	}
+	err = stub.PutState(feeScheduleKey, scheduleAsBytes)
+	if err != nil {
+		return shim.Error("failed to put state of fee schedule: " + err.Error())
+	}
+	err = stub.SetEvent("fee_schedule_updated", scheduleAsBytes)
+	if err != nil {
+		return shim.Error("failed to set event `fee_schedule_updated`: " + err.Error())
+	}
+
+	fmt.Println("[DEBUG] end transfer.SetFeeSchedule")
+	return shim.Success(scheduleAsBytes)
+}
+
+// GetFeeSchedule - Queries the fee schedule
+// params: none
+func GetFeeSchedule(stub shim.ChaincodeStubInterface) peer.Response {
+	fmt.Println("[DEBUG] begin transfer.GetFeeSchedule")
+
+	scheduleAsBytes, err := stub.GetState(feeScheduleKey)
+	if err != nil {
+		return shim.Error("failed to get state of fee schedule: " + err.Error())
+	} else if scheduleAsBytes == nil {
+		return shim.Error("fee schedule does not exist")
+	}
+
+	fmt.Println("[DEBUG] end transfer.GetFeeSchedule")
+	return shim.Success(scheduleAsBytes)
+}
+
+// getFeeSchedule - Reads the fee schedule, or nil when no fees are charged
+func getFeeSchedule(stub shim.ChaincodeStubInterface) (*FeeSchedule, error) {
+	scheduleAsBytes, err := stub.GetState(feeScheduleKey)
+	if err != nil {
+		return nil, errors.New("failed to get state of fee schedule: " + err.Error())
+	} else if scheduleAsBytes == nil {
+		return nil, nil
+	}
+
+	schedule := &FeeSchedule{}
+	err = json.Unmarshal(scheduleAsBytes, schedule)
+	if err != nil {
+		return nil, errors.New("cannot unmarshal fee schedule to JSON: " + err.Error())
+	}
+	return schedule, nil
+}
+
+// fee - Computes the fee of a transfer value
+func (s *FeeSchedule) fee(value int) int {
+	var fee int
+
+	switch s.Type {
+	case feeFlat:
+		fee = s.Flat
+	case feePercentage:
+		fee = value * s.BasisPoints / 10000
+	case feeTiered:
+		for _, tier := range s.Tiers {
+			if tier.UpTo == 0 || value <= tier.UpTo {
+				fee = tier.Flat + value*tier.BasisPoints/10000
+				break
+			}
+		}
+	}
+
+	if fee < s.Min {
+		fee = s.Min
+	}
+	if s.Max > 0 && fee > s.Max {
+		fee = s.Max
+	}
+	return fee
+}
+
+// validate - Checks a fee schedule is consistent
+func (s *FeeSchedule) validate() error {
+	if s.Flat < 0 || s.BasisPoints < 0 || s.Min < 0 || s.Max < 0 {
+		return errors.New("fee amounts must not be negative")
+	}
+	if s.Max > 0 && s.Min > s.Max {
+		return errors.New("fee minimum must not be greater than the maximum")
+	}
+
+	switch s.Type {
+	case feeFlat, feePercentage:
+		return nil
+	case feeTiered:
+		if len(s.Tiers) == 0 {
+			return errors.New("tiered fee schedule needs at least one tier")
+		}
+		for i, tier := range s.Tiers {
+			if tier.Flat < 0 || tier.BasisPoints < 0 || tier.UpTo < 0 {
+				return errors.New("fee tier amounts must not be negative")
+			}
+			if i > 0 && (s.Tiers[i-1].UpTo == 0 || tier.UpTo != 0 && tier.UpTo <= s.Tiers[i-1].UpTo) {
+				return errors.New("fee tiers must be sorted by increasing upTo, unbounded tier last")
+			}
+		}
+		return nil
+	default:
+		return errors.New("fee type must be " + feeFlat + ", " + feePercentage + " or " + feeTiered)
+	}
+}
@@ -0,0 +1,257 @@
+package transfer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/hyperledger-fabric-go-chaincodes/auth"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/peer"
+)
+
+// transferLimitsKey - state key holding the transfer limits of every account tier
+const transferLimitsKey = "CFG_LIMITS"
+
+// defaultTier - tier of accounts without one
+const defaultTier = "standard"
+
+// limitExceeded - prefix of the errors of transfers refused by a limit
+const limitExceeded = "LIMIT_EXCEEDED"
+
+// Limits structure with the outgoing transfer limits of an account tier. 0 means no limit
+type Limits struct {
+	MaxPerTransfer int `json:"maxPerTransfer"`
+	DailyTotal     int `json:"dailyTotal"`
+	DailyCount     int `json:"dailyCount"`
+}
+
+// DailyUsage structure with the outgoing transfers of an account on a day
+// (UTC, from the transaction timestamp). It is stored as VEL<accountNumber>-<date>
+type DailyUsage struct {
+	ObjectType    string `json:"docType"`
+	AccountNumber int    `json:"accountNumber"`
+	Date          string `json:"date"`
+	Total         int    `json:"total"`
+	Count         int    `json:"count"`
+}
+
+// SetTransferLimits - Sets the limits of an account tier. Restricted to admins
+// params: Tier, MaxPerTransfer, DailyTotal, DailyCount
+func SetTransferLimits(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	fmt.Println("[DEBUG] begin transfer.SetTransferLimits")
+
+	err := auth.Require(stub, auth.Admin)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// Input sanitation
+	if len(args) != 4 {
+		return shim.Error("incorrect number of arguments. 4 expected")
+	}
+	if args[0] == "" {
+		return shim.Error("1st argument must be a non-empty string")
+	}
+	var values [3]int
+	for i := range values {
+		values[i], err = strconv.Atoi(args[i+1])
+		if err != nil || values[i] < 0 {
+			return shim.Error("limits must be non-negative numeric strings")
+		}
+	}
+
+	limits, err := getTransferLimits(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	limits[args[0]] = Limits{MaxPerTransfer: values[0], DailyTotal: values[1], DailyCount: values[2]}
+
+	limitsAsBytes, err := json.Marshal(limits)
+	if err != nil {
+		return shim.Error("failed to marshal transfer limits: " + err.Error())
+	}
+	err = stub.PutState(transferLimitsKey, limitsAsBytes)
+	if err != nil {
+		return shim.Error("failed to put state of transfer limits: " + err.Error())
+	}
+	err = stub.SetEvent("transfer_limits_updated", limitsAsBytes)
+	if err != nil {
+		return shim.Error("failed to set event `transfer_limits_updated`: " + err.Error())
+	}
+
+	fmt.Println("[DEBUG] end transfer.SetTransferLimits")
+	return shim.Success(limitsAsBytes)
+}
+
+// GetTransferLimits - Queries the limits of every account tier
+// params: none
+func GetTransferLimits(stub shim.ChaincodeStubInterface) peer.Response {
+	fmt.Println("[DEBUG] begin transfer.GetTransferLimits")
+
+	limits, err := getTransferLimits(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	limitsAsBytes, err := json.Marshal(limits)
+	if err != nil {
+		return shim.Error("failed to marshal transfer limits: " + err.Error())
+	}
+
+	fmt.Println("[DEBUG] end transfer.GetTransferLimits")
+	return shim.Success(limitsAsBytes)
+}
+
+// GetDailyUsage - Queries the outgoing transfers of an account on a day
+// params: AccountNumber, Date
+func GetDailyUsage(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	fmt.Println("[DEBUG] begin transfer.GetDailyUsage")
+
+	// Input sanitation
+	if len(args) != 2 {
+		return shim.Error("incorrect number of arguments. 2 expected")
+	}
+	accNumber, err := strconv.Atoi(args[0])
+	if err != nil {
+		return shim.Error("1st argument must be a numeric string")
+	}
+	date, err := parseDate(args[1])
+	if err != nil {
+		return shim.Error("2nd argument must be a date: " + err.Error())
+	}
+
+	usage, err := getDailyUsage(stub, accNumber, date.Format("2006-01-02"))
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	usageAsBytes, err := json.Marshal(usage)
+	if err != nil {
+		return shim.Error("failed to marshal daily usage: " + err.Error())
+	}
+
+	fmt.Println("[DEBUG] end transfer.GetDailyUsage")
+	return shim.Success(usageAsBytes)
+}
+
+// checkLimits - Checks outgoing transfers of a loaded payer against the limits
+// of its tier, or of the default tier when its own has none. Returns the daily
+// usage counting them, to be recorded with spend once they are executed, or nil
+// when no limits apply
+func checkLimits(stub shim.ChaincodeStubInterface, accounts *ledgerAccounts, payerAccNumber int, values ...int) (*DailyUsage, error) {
+	payerAcc, err := accounts.get(payerAccNumber)
+	if err != nil {
+		return nil, err
+	}
+	limits, err := getTransferLimits(stub)
+	if err != nil {
+		return nil, err
+	}
+	tier, err := accounts.tier(payerAcc)
+	if err != nil {
+		return nil, err
+	}
+	if tier == "" {
+		tier = defaultTier
+	}
+	// Tiers without limits of their own get those of the default tier
+	tierLimits, found := limits[tier]
+	if !found {
+		tier = defaultTier
+		tierLimits, found = limits[defaultTier]
+	}
+	if !found {
+		return nil, nil
+	}
+
+	now, err := txTime(stub)
+	if err != nil {
+		return nil, err
+	}
+	date := now.Format("2006-01-02")
+
+	// Usage already counted in this transaction is not readable from the ledger
+	usage := &DailyUsage{}
+	if counted, ok := accounts.usages[payerAcc.AccountNumber]; ok && counted.Date == date {
+		*usage = *counted
+	} else {
+		usage, err = getDailyUsage(stub, payerAcc.AccountNumber, date)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, value := range values {
+		if tierLimits.MaxPerTransfer > 0 && value > tierLimits.MaxPerTransfer {
+			return nil, fmt.Errorf("%s: %d exceeds the maximum of %d per transfer of tier %s", limitExceeded, value, tierLimits.MaxPerTransfer, tier)
+		}
+		usage.Total += value
+		usage.Count++
+	}
+	if tierLimits.DailyTotal > 0 && usage.Total > tierLimits.DailyTotal {
+		return nil, fmt.Errorf("%s: daily total of %d of tier %s would be exceeded", limitExceeded, tierLimits.DailyTotal, tier)
+	}
+	if tierLimits.DailyCount > 0 && usage.Count > tierLimits.DailyCount {
+		return nil, fmt.Errorf("%s: daily count of %d transfers of tier %s would be exceeded", limitExceeded, tierLimits.DailyCount, tier)
+	}
+	return usage, nil
+}
+
+// spend - Records the daily usage returned by checkLimits, once the transfers
+// it counts are executed, so that failed or skipped ones are not counted
+func spend(stub shim.ChaincodeStubInterface, accounts *ledgerAccounts, usage *DailyUsage) error {
+	if usage == nil {
+		return nil
+	}
+	usageAsBytes, err := json.Marshal(usage)
+	if err != nil {
+		return errors.New("failed to marshal daily usage: " + err.Error())
+	}
+	err = stub.PutState(dailyUsageKey(usage.AccountNumber, usage.Date), usageAsBytes)
+	if err != nil {
+		return errors.New("failed to put state of daily usage: " + err.Error())
+	}
+	accounts.usages[usage.AccountNumber] = usage
+	return nil
+}
+
+// getTransferLimits - Reads the limits of every account tier
+func getTransferLimits(stub shim.ChaincodeStubInterface) (map[string]Limits, error) {
+	limits := make(map[string]Limits)
+
+	limitsAsBytes, err := stub.GetState(transferLimitsKey)
+	if err != nil {
+		return nil, errors.New("failed to get state of transfer limits: " + err.Error())
+	} else if limitsAsBytes == nil {
+		return limits, nil
+	}
+
+	err = json.Unmarshal(limitsAsBytes, &limits)
+	if err != nil {
+		return nil, errors.New("cannot unmarshal transfer limits to JSON: " + err.Error())
+	}
+	return limits, nil
+}
+
+// getDailyUsage - Reads the daily usage of an account, empty when it did not transfer yet
+func getDailyUsage(stub shim.ChaincodeStubInterface, accNumber int, date string) (*DailyUsage, error) {
+	usage := &DailyUsage{ObjectType: "DailyUsage", AccountNumber: accNumber, Date: date}
+
+	usageAsBytes, err := stub.GetState(dailyUsageKey(accNumber, date))
+	if err != nil {
+		return nil, errors.New("failed to get state of daily usage: " + err.Error())
+	} else if usageAsBytes == nil {
+		return usage, nil
+	}
+
+	err = json.Unmarshal(usageAsBytes, usage)
+	if err != nil {
+		return nil, errors.New("cannot unmarshal daily usage to JSON: " + err.Error())
+	}
+	return usage, nil
+}
+
+// dailyUsageKey - Key of the daily usage of an account
+func dailyUsageKey(accNumber int, date string) string {
+	return "VEL" + strconv.Itoa(accNumber) + "-" + date
+}
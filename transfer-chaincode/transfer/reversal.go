@@ -0,0 +1,137 @@
+package transfer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/hyperledger-fabric-go-chaincodes/auth"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/peer"
+)
+
+// Reverse - Sends back (part of) a transfer from its receiver to its payer with
+// a compensating transfer linked to the original one. The reversed values of a
+// transfer cannot add up to more than its value, and reversals cannot be
+// reversed. Fees are neither charged nor refunded. Restricted to operators
+// params: TxID, [Value], [Reason]
+func Reverse(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	fmt.Println("[DEBUG] begin transfer.Reverse")
+
+	err := auth.Require(stub, auth.Operator, auth.Admin)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// Input sanitation
+	if len(args) < 1 || len(args) > 3 {
+		return shim.Error("incorrect number of arguments. 1 to 3 expected")
+	}
+	if args[0] == "" {
+		return shim.Error("1st argument must be a non-empty string")
+	}
+
+	original, err := getTransfer(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if original.ReversalOf != "" {
+		return shim.Error("transfer " + original.TxID + " is a reversal and cannot be reversed")
+	}
+	remaining := original.Value - original.ReversedValue
+	if remaining <= 0 {
+		return shim.Error("transfer " + original.TxID + " is already reversed")
+	}
+
+	// Reverse the remaining value unless a partial value is given
+	value := remaining
+	if len(args) > 1 && args[1] != "" {
+		value, err = strconv.Atoi(args[1])
+		if err != nil || value <= 0 {
+			return shim.Error("2nd argument must be a positive numeric string")
+		}
+		if value > remaining {
+			return shim.Error("only " + strconv.Itoa(remaining) + " of transfer " + original.TxID + " can still be reversed")
+		}
+	}
+
+	reversal := &Transfer{
+		ObjectType:            "Transfer",
+		TxID:                  stub.GetTxID(),
+		PayerAccountNumber:    original.ReceiverAccountNumber,
+		ReceiverAccountNumber: original.PayerAccountNumber,
+		Value:                 value,
+		ReversalOf:            original.TxID,
+	}
+	if len(args) == 3 {
+		reversal.Reason = args[2]
+	}
+
+	// Move the money back, checking the original receiver funds
+	accounts, err := loadAccounts(stub, reversal.PayerAccountNumber, reversal.ReceiverAccountNumber)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	err = accounts.move(reversal.PayerAccountNumber, reversal.ReceiverAccountNumber, value)
+	if err == errInsufficientFunds {
+		return shim.Error("receiver of transfer " + original.TxID + " has insufficient funds")
+	} else if err != nil {
+		return shim.Error(err.Error())
+	}
+	err = accounts.save()
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// Link both transfers
+	original.ReversedValue += value
+	original.Reversals = append(original.Reversals, reversal.TxID)
+	_, err = putTransfer(stub, original)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	reversalAsBytes, err := putTransfer(stub, reversal)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// Notify listeners about the compensating transfer
+	err = stub.SetEvent("money_transferred", reversalAsBytes)
+	if err != nil {
+		return shim.Error("failed to set event `money_transferred`: " + err.Error())
+	}
+
+	fmt.Println("[DEBUG] end transfer.Reverse")
+	return shim.Success(reversalAsBytes)
+}
+
+// getTransfer - Reads the transfer recorded as TRF<txId>
+func getTransfer(stub shim.ChaincodeStubInterface, txID string) (*Transfer, error) {
+	transferAsBytes, err := stub.GetState("TRF" + txID)
+	if err != nil {
+		return nil, errors.New("failed to get state of transfer " + txID + ": " + err.Error())
+	} else if transferAsBytes == nil {
+		return nil, errors.New("transfer " + txID + " does not exist")
+	}
+
+	transfer := &Transfer{}
+	err = json.Unmarshal(transferAsBytes, transfer)
+	if err != nil {
+		return nil, errors.New("cannot unmarshal transfer to JSON: " + err.Error())
+	}
+	return transfer, nil
+}
+
+// putTransfer - Writes a transfer as TRF<txId> and returns it as JSON
+func putTransfer(stub shim.ChaincodeStubInterface, transfer *Transfer) ([]byte, error) {
+	transferAsBytes, err := json.Marshal(transfer)
+	if err != nil {
+		return nil, errors.New("failed to marshal transfer object: " + err.Error())
+	}
+	err = stub.PutState("TRF"+transfer.TxID, transferAsBytes)
+	if err != nil {
+		return nil, errors.New("failed to put state of transfer: " + err.Error())
+	}
+	return transferAsBytes, nil
+}
@@ -0,0 +1,512 @@
+package transfer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/hyperledger-fabric-go-chaincodes/auth"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/peer"
+)
+
+// Schedule status
+const (
+	scheduleActive    = "active"
+	scheduleCompleted = "completed"
+	scheduleCancelled = "cancelled"
+)
+
+// Execution status
+const (
+	executionExecuted = "executed"
+	executionSkipped  = "skipped"
+)
+
+// maxCatchUp - maximum number of occurrences of a schedule processed by one
+// ExecuteDue, so that a long-missed schedule cannot exhaust the transaction.
+// The following ones are caught up by the next calls
+const maxCatchUp = 100
+
+// Schedule structure of a standing order. It is stored as SCH<id>, where id is
+// the ID of the transaction that created it. Dates are RFC3339 strings and the
+// interval is a count followed by a unit: d (days), w (weeks), m (months) or y (years)
+type Schedule struct {
+	ObjectType            string `json:"docType"`
+	ID                    string `json:"id"`
+	PayerAccountNumber    int    `json:"payerAccountNumber"`
+	ReceiverAccountNumber int    `json:"receiverAccountNumber"`
+	Value                 int    `json:"value"`
+	StartDate             string `json:"startDate"`
+	Interval              string `json:"interval"`
+	EndDate               string `json:"endDate,omitempty"`
+	MaxExecutions         int    `json:"maxExecutions,omitempty"`
+	NextDate              string `json:"nextDate"`
+	Executions            int    `json:"executions"`
+	Status                string `json:"status"`
+}
+
+// Execution structure with one occurrence of a schedule, executed or skipped.
+// It is stored as EXE<scheduleId>-<occurrence>
+type Execution struct {
+	ObjectType string `json:"docType"`
+	ScheduleID string `json:"scheduleId"`
+	Occurrence int    `json:"occurrence"`
+	DueDate    string `json:"dueDate"`
+	TxID       string `json:"txId"`
+	Status     string `json:"status"`
+	Reason     string `json:"reason,omitempty"`
+}
+
+// CreateSchedule - Stores a scheduled (recurring) transfer instruction. It ends
+// after the end date or the maximum number of executions, when given. Values
+// above the approval threshold are refused
+// param: AccountNumber, AccountNumber, Value, StartDate, Interval, [EndDate], [MaxExecutions]
+func CreateSchedule(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	fmt.Println("[DEBUG] begin transfer.CreateSchedule")
+
+	// Input sanitation
+	if len(args) < 5 || len(args) > 7 {
+		return shim.Error("incorrect number of arguments. 5 to 7 expected")
+	}
+	payerAccNumber, err := strconv.Atoi(args[0])
+	if err != nil {
+		return shim.Error("1st argument must be a numeric string")
+	}
+	receiverAccNumber, err := strconv.Atoi(args[1])
+	if err != nil {
+		return shim.Error("2nd argument must be a numeric string")
+	}
+	if payerAccNumber == receiverAccNumber {
+		return shim.Error("the transfer must be between different accounts")
+	}
+	value, err := strconv.Atoi(args[2])
+	if err != nil || value <= 0 {
+		return shim.Error("3rd argument must be a positive numeric string")
+	}
+	startDate, err := parseDate(args[3])
+	if err != nil {
+		return shim.Error("4th argument must be a date: " + err.Error())
+	}
+	_, err = addInterval(startDate, args[4], 1)
+	if err != nil {
+		return shim.Error("5th argument must be an interval: " + err.Error())
+	}
+
+	schedule := &Schedule{
+		ObjectType:            "Schedule",
+		ID:                    stub.GetTxID(),
+		PayerAccountNumber:    payerAccNumber,
+		ReceiverAccountNumber: receiverAccNumber,
+		Value:                 value,
+		StartDate:             formatDate(startDate),
+		Interval:              args[4],
+		NextDate:              formatDate(startDate),
+		Status:                scheduleActive,
+	}
+	if len(args) > 5 && args[5] != "" {
+		endDate, err := parseDate(args[5])
+		if err != nil || endDate.Before(startDate) {
+			return shim.Error("6th argument must be a date not before the start date")
+		}
+		schedule.EndDate = formatDate(endDate)
+	}
+	if len(args) > 6 && args[6] != "" {
+		schedule.MaxExecutions, err = strconv.Atoi(args[6])
+		if err != nil || schedule.MaxExecutions <= 0 {
+			return shim.Error("7th argument must be a positive numeric string")
+		}
+	}
+
+	// Check the caller may debit the payer account, without approvals
+	err = authorizeSingleDebit(stub, payerAccNumber)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	policy, err := getApprovalPolicy(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	err = policy.requireNoApproval(value)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// Check both accounts exist
+	accounts, err := loadAccounts(stub, payerAccNumber, receiverAccNumber)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	for _, accNumber := range []int{payerAccNumber, receiverAccNumber} {
+		if _, err := accounts.get(accNumber); err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+
+	scheduleAsBytes, err := putSchedule(stub, schedule)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	err = stub.SetEvent("schedule_created", scheduleAsBytes)
+	if err != nil {
+		return shim.Error("failed to set event `schedule_created`: " + err.Error())
+	}
+
+	fmt.Println("[DEBUG] end transfer.CreateSchedule")
+	return shim.Success(scheduleAsBytes)
+}
+
+// CancelSchedule - Stops a scheduled transfer. Restricted to the owners of the
+// payer account and operators
+// param: ScheduleID
+func CancelSchedule(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	fmt.Println("[DEBUG] begin transfer.CancelSchedule")
+
+	// Input sanitation
+	if len(args) != 1 {
+		return shim.Error("incorrect number of arguments. 1 expected")
+	}
+
+	schedule, err := getSchedule(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if schedule.Status != scheduleActive {
+		return shim.Error("schedule " + schedule.ID + " is already " + schedule.Status)
+	}
+
+	// Check the caller may debit the payer account. Any owner may stop its debits
+	_, _, err = authorizeDebit(stub, schedule.PayerAccountNumber)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	schedule.Status = scheduleCancelled
+	scheduleAsBytes, err := putSchedule(stub, schedule)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	err = stub.SetEvent("schedule_cancelled", scheduleAsBytes)
+	if err != nil {
+		return shim.Error("failed to set event `schedule_cancelled`: " + err.Error())
+	}
+
+	fmt.Println("[DEBUG] end transfer.CancelSchedule")
+	return shim.Success(scheduleAsBytes)
+}
+
+// ExecuteDue - Executes every scheduled transfer due as of the transaction
+// timestamp, catching up on missed occurrences, up to maxCatchUp per schedule.
+// Occurrences that cannot be paid are skipped with a recorded reason.
+// Restricted to operators
+// params: none
+func ExecuteDue(stub shim.ChaincodeStubInterface) peer.Response {
+	fmt.Println("[DEBUG] begin transfer.ExecuteDue")
+
+	err := auth.Require(stub, auth.Operator, auth.Admin)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	now, err := txTime(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// Collect due schedules
+	schedulesIterator, err := stub.GetStateByRange("SCH", "SCI")
+	if err != nil {
+		return shim.Error("cannot get schedules: " + err.Error())
+	}
+	defer schedulesIterator.Close()
+
+	var due []*Schedule
+	var accNumbers []int
+	for schedulesIterator.HasNext() {
+		scheduleKV, err := schedulesIterator.Next()
+		if err != nil {
+			return shim.Error("failed to iterate over schedules: " + err.Error())
+		}
+		schedule := &Schedule{}
+		err = json.Unmarshal(scheduleKV.Value, schedule)
+		if err != nil {
+			return shim.Error("cannot unmarshal schedule to JSON: " + err.Error())
+		}
+		nextDate, err := parseDate(schedule.NextDate)
+		if err != nil {
+			return shim.Error("schedule " + schedule.ID + " has an invalid next date: " + err.Error())
+		}
+		if schedule.Status == scheduleActive && !nextDate.After(now) {
+			due = append(due, schedule)
+			accNumbers = append(accNumbers, schedule.PayerAccountNumber, schedule.ReceiverAccountNumber)
+		}
+	}
+
+	executions := []*Execution{}
+	if len(due) > 0 {
+		accounts, err := loadAccounts(stub, accNumbers...)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		policy, err := getApprovalPolicy(stub)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+
+		for _, schedule := range due {
+			scheduleExecutions, err := executeSchedule(stub, accounts, policy, schedule, now)
+			if err != nil {
+				return shim.Error(err.Error())
+			}
+			executions = append(executions, scheduleExecutions...)
+
+			_, err = putSchedule(stub, schedule)
+			if err != nil {
+				return shim.Error(err.Error())
+			}
+		}
+
+		err = accounts.save()
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+
+	// Record each execution
+	for _, execution := range executions {
+		executionAsBytes, err := json.Marshal(execution)
+		if err != nil {
+			return shim.Error("failed to marshal execution object: " + err.Error())
+		}
+		err = stub.PutState(executionKey(execution.ScheduleID, execution.Occurrence), executionAsBytes)
+		if err != nil {
+			return shim.Error("failed to put state of execution: " + err.Error())
+		}
+	}
+
+	executionsAsBytes, err := json.Marshal(executions)
+	if err != nil {
+		return shim.Error("failed to marshal executions: " + err.Error())
+	}
+	err = stub.SetEvent("scheduled_transfers_executed", executionsAsBytes)
+	if err != nil {
+		return shim.Error("failed to set event `scheduled_transfers_executed`: " + err.Error())
+	}
+
+	fmt.Println("[DEBUG] end transfer.ExecuteDue")
+	return shim.Success(executionsAsBytes)
+}
+
+// GetSchedule - Queries a scheduled transfer by its ID
+// param: ScheduleID
+func GetSchedule(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	fmt.Println("[DEBUG] begin transfer.GetSchedule")
+
+	// Input sanitation
+	if len(args) != 1 {
+		return shim.Error("incorrect number of arguments. 1 expected")
+	}
+
+	scheduleAsBytes, err := stub.GetState("SCH" + args[0])
+	if err != nil {
+		return shim.Error("failed to get state of schedule " + args[0] + ": " + err.Error())
+	} else if scheduleAsBytes == nil {
+		return shim.Error("schedule " + args[0] + " does not exist")
+	}
+
+	fmt.Println("[DEBUG] end transfer.GetSchedule")
+	return shim.Success(scheduleAsBytes)
+}
+
+// GetExecutions - Queries the recorded executions of a scheduled transfer
+// param: ScheduleID
+func GetExecutions(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	fmt.Println("[DEBUG] begin transfer.GetExecutions")
+
+	// Input sanitation
+	if len(args) != 1 {
+		return shim.Error("incorrect number of arguments. 1 expected")
+	}
+
+	// Execution keys are EXE<scheduleId>-<occurrence>, '.' is the character after '-'
+	executionsIterator, err := stub.GetStateByRange("EXE"+args[0]+"-", "EXE"+args[0]+".")
+	if err != nil {
+		return shim.Error("cannot get executions: " + err.Error())
+	}
+	defer executionsIterator.Close()
+
+	executions := []json.RawMessage{}
+	for executionsIterator.HasNext() {
+		executionKV, err := executionsIterator.Next()
+		if err != nil {
+			return shim.Error("failed to iterate over executions: " + err.Error())
+		}
+		executions = append(executions, executionKV.Value)
+	}
+	executionsAsBytes, err := json.Marshal(executions)
+	if err != nil {
+		return shim.Error("failed to marshal executions: " + err.Error())
+	}
+
+	fmt.Println("[DEBUG] end transfer.GetExecutions")
+	return shim.Success(executionsAsBytes)
+}
+
+// executeSchedule - Processes the occurrences of a schedule due as of now, at
+// most maxCatchUp, on the loaded accounts and advances the schedule. Occurrences
+// above the approval threshold, which may have been lowered since the schedule
+// was created, or over the transfer limits of the payer are skipped
+func executeSchedule(stub shim.ChaincodeStubInterface, accounts *ledgerAccounts, policy *ApprovalPolicy, schedule *Schedule, now time.Time) ([]*Execution, error) {
+	var executions []*Execution
+
+	startDate, err := parseDate(schedule.StartDate)
+	if err != nil {
+		return nil, errors.New("schedule " + schedule.ID + " has an invalid start date: " + err.Error())
+	}
+	nextDate, err := parseDate(schedule.NextDate)
+	if err != nil {
+		return nil, errors.New("schedule " + schedule.ID + " has an invalid next date: " + err.Error())
+	}
+
+	for schedule.Status == scheduleActive && !nextDate.After(now) && len(executions) < maxCatchUp {
+		if schedule.EndDate != "" && schedule.NextDate > schedule.EndDate {
+			schedule.Status = scheduleCompleted
+			break
+		}
+
+		execution := &Execution{
+			ObjectType: "Execution",
+			ScheduleID: schedule.ID,
+			Occurrence: schedule.Executions + 1,
+			DueDate:    schedule.NextDate,
+			TxID:       stub.GetTxID(),
+			Status:     executionExecuted,
+		}
+		var usage *DailyUsage
+		err = policy.requireNoApproval(schedule.Value)
+		if err == nil {
+			usage, err = checkLimits(stub, accounts, schedule.PayerAccountNumber, schedule.Value)
+		}
+		if err == nil {
+			err = accounts.move(schedule.PayerAccountNumber, schedule.ReceiverAccountNumber, schedule.Value)
+		}
+		if err == nil {
+			err = spend(stub, accounts, usage)
+		}
+		if err != nil {
+			execution.Status, execution.Reason = executionSkipped, err.Error()
+		}
+		executions = append(executions, execution)
+
+		// Occurrences are computed from the start date, so that a day clamped to a
+		// short month, e.g. February 28 for January 31, is not kept afterwards
+		schedule.Executions++
+		nextDate, err = addInterval(startDate, schedule.Interval, schedule.Executions)
+		if err != nil {
+			return nil, errors.New("schedule " + schedule.ID + " has an invalid interval: " + err.Error())
+		}
+		schedule.NextDate = formatDate(nextDate)
+		if schedule.MaxExecutions > 0 && schedule.Executions >= schedule.MaxExecutions {
+			schedule.Status = scheduleCompleted
+		}
+	}
+	return executions, nil
+}
+
+// getSchedule - Reads a schedule from the ledger
+func getSchedule(stub shim.ChaincodeStubInterface, id string) (*Schedule, error) {
+	scheduleAsBytes, err := stub.GetState("SCH" + id)
+	if err != nil {
+		return nil, errors.New("failed to get state of schedule " + id + ": " + err.Error())
+	} else if scheduleAsBytes == nil {
+		return nil, errors.New("schedule " + id + " does not exist")
+	}
+
+	schedule := &Schedule{}
+	err = json.Unmarshal(scheduleAsBytes, schedule)
+	if err != nil {
+		return nil, errors.New("cannot unmarshal schedule to JSON: " + err.Error())
+	}
+	return schedule, nil
+}
+
+// putSchedule - Writes a schedule to the ledger and returns it as JSON
+func putSchedule(stub shim.ChaincodeStubInterface, schedule *Schedule) ([]byte, error) {
+	scheduleAsBytes, err := json.Marshal(schedule)
+	if err != nil {
+		return nil, errors.New("failed to marshal schedule object: " + err.Error())
+	}
+	err = stub.PutState("SCH"+schedule.ID, scheduleAsBytes)
+	if err != nil {
+		return nil, errors.New("failed to put state of schedule: " + err.Error())
+	}
+	return scheduleAsBytes, nil
+}
+
+// executionKey - Key of an execution, padded so that executions sort by occurrence
+func executionKey(scheduleID string, occurrence int) string {
+	return fmt.Sprintf("EXE%s-%06d", scheduleID, occurrence)
+}
+
+// txTime - Returns the transaction timestamp, which is the same on every endorser
+func txTime(stub shim.ChaincodeStubInterface) (time.Time, error) {
+	timestamp, err := stub.GetTxTimestamp()
+	if err != nil {
+		return time.Time{}, errors.New("cannot get transaction timestamp: " + err.Error())
+	}
+	return time.Unix(timestamp.Seconds, int64(timestamp.Nanos)).UTC(), nil
+}
+
+// parseDate - Parses an RFC3339 date-time or a plain date (midnight UTC)
+func parseDate(date string) (time.Time, error) {
+	parsed, err := time.Parse(time.RFC3339, date)
+	if err != nil {
+		parsed, err = time.Parse("2006-01-02", date)
+	}
+	return parsed.UTC(), err
+}
+
+// formatDate - Formats dates the way they are stored, so they compare as strings
+func formatDate(date time.Time) string {
+	return date.UTC().Format(time.RFC3339)
+}
+
+// addInterval - Adds n times an interval such as "1m" (month) to a date. Months
+// and years missing the day of the date end on their last day instead, e.g. a
+// month after January 31 is February 28 or 29
+func addInterval(date time.Time, interval string, n int) (time.Time, error) {
+	if len(interval) < 2 {
+		return date, errors.New("interval must be a count followed by d, w, m or y")
+	}
+	count, err := strconv.Atoi(interval[:len(interval)-1])
+	if err != nil || count <= 0 {
+		return date, errors.New("interval count must be a positive number")
+	}
+
+	count *= n
+	switch interval[len(interval)-1] {
+	case 'd':
+		return date.AddDate(0, 0, count), nil
+	case 'w':
+		return date.AddDate(0, 0, 7*count), nil
+	case 'm':
+		return addMonths(date, count), nil
+	case 'y':
+		return addMonths(date, 12*count), nil
+	default:
+		return date, errors.New("interval unit must be d, w, m or y")
+	}
+}
+
+// addMonths - Adds months to a date, clamping its day to the last day of the
+// resulting month where AddDate would overflow into the next one
+func addMonths(date time.Time, months int) time.Time {
+	firstDay := time.Date(date.Year(), date.Month(), 1, 0, 0, 0, 0, date.Location()).AddDate(0, months, 0)
+	lastDay := firstDay.AddDate(0, 1, -1).Day()
+	day := date.Day()
+	if day > lastDay {
+		day = lastDay
+	}
+	return time.Date(firstDay.Year(), firstDay.Month(), day, date.Hour(), date.Minute(), date.Second(), date.Nanosecond(), date.Location())
+}
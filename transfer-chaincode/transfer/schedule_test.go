@@ -0,0 +1,46 @@
+package transfer
+
+import (
+	"testing"
+)
+
+func TestAddIntervalClampsMonths(t *testing.T) {
+	start, err := parseDate("2019-01-31")
+	if err != nil {
+		t.Fatalf("cannot parse start date: %v", err)
+	}
+
+	tests := []struct {
+		interval string
+		n        int
+		expected string
+	}{
+		{"1m", 1, "2019-02-28T00:00:00Z"},
+		{"1m", 2, "2019-03-31T00:00:00Z"},
+		{"1m", 3, "2019-04-30T00:00:00Z"},
+		{"1m", 13, "2020-02-29T00:00:00Z"},
+		{"2m", 6, "2020-01-31T00:00:00Z"},
+		{"1d", 1, "2019-02-01T00:00:00Z"},
+		{"1w", 1, "2019-02-07T00:00:00Z"},
+		{"1y", 1, "2020-01-31T00:00:00Z"},
+	}
+	for _, test := range tests {
+		next, err := addInterval(start, test.interval, test.n)
+		if err != nil {
+			t.Errorf("%d times %s after %s: %v", test.n, test.interval, formatDate(start), err)
+			continue
+		}
+		if got := formatDate(next); got != test.expected {
+			t.Errorf("%d times %s after %s is %s, %s expected", test.n, test.interval, formatDate(start), got, test.expected)
+		}
+	}
+
+	leapDay, err := parseDate("2020-02-29")
+	if err != nil {
+		t.Fatalf("cannot parse leap day: %v", err)
+	}
+	next, err := addInterval(leapDay, "1y", 1)
+	if err != nil || formatDate(next) != "2021-02-28T00:00:00Z" {
+		t.Errorf("a year after %s is %s (error %v), 2021-02-28T00:00:00Z expected", formatDate(leapDay), formatDate(next), err)
+	}
+}
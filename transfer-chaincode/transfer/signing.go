@@ -0,0 +1,159 @@
+package transfer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/hyperledger-fabric-go-chaincodes/account-chaincode/account"
+	"github.com/hyperledger-fabric-go-chaincodes/auth"
+	"github.com/hyperledger/fabric/common/util"
+	"github.com/hyperledger/fabric/core/chaincode/lib/cid"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/peer"
+)
+
+// Sign - Signs a pending transfer debiting a joint account whose owners must all
+// sign. The transfer is executed once every owner signed and the approvals, if
+// any, are reached. Restricted to the owners of the payer account
+// param: PendingTransferID
+func Sign(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	fmt.Println("[DEBUG] begin transfer.Sign")
+
+	// Input sanitation
+	if len(args) != 1 {
+		return shim.Error("incorrect number of arguments. 1 expected")
+	}
+
+	pending, err := getPendingTransfer(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if pending.Status != pendingApproval {
+		return shim.Error("pending transfer " + pending.ID + " is already " + pending.Status)
+	}
+
+	signer, err := getSigner(stub, pending.PayerAccountNumber)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if !contains(pending.RequiredSigners, signer.CustomerID) {
+		return shim.Error("client identity is not a signer of pending transfer " + pending.ID)
+	}
+	if pending.signed(signer.CustomerID) {
+		return shim.Error("pending transfer " + pending.ID + " was already signed by customer " + signer.CustomerID)
+	}
+	signature, err := newDecision(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	signature.CustomerID = signer.CustomerID
+	pending.Signatures = append(pending.Signatures, *signature)
+
+	// Execute once every signature and approval is there
+	eventName := "transfer_signed"
+	if pending.ready() {
+		eventName, err = executePending(stub, pending)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+
+	pendingAsBytes, err := putPendingTransfer(stub, pending)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// Executed transfers already notified listeners with `money_transferred`
+	if eventName != "" {
+		err = stub.SetEvent(eventName, pendingAsBytes)
+		if err != nil {
+			return shim.Error("failed to set event `" + eventName + "`: " + err.Error())
+		}
+	}
+
+	fmt.Println("[DEBUG] end transfer.Sign")
+	return shim.Success(pendingAsBytes)
+}
+
+// getSigner - Asks the account chaincode who may sign debits of an account and
+// which of its owners the caller is
+func getSigner(stub shim.ChaincodeStubInterface, accNumber int) (*account.Signer, error) {
+	response := stub.InvokeChaincode(accountChaincode, util.ToChaincodeArgs("GetSigner", strconv.Itoa(accNumber)), "")
+	if response.Status != shim.OK {
+		return nil, errors.New("failed to invoke `" + accountChaincode + "` chaincode: " + response.Message)
+	}
+
+	signer := &account.Signer{}
+	err := json.Unmarshal(response.Payload, signer)
+	if err != nil {
+		return nil, errors.New("cannot unmarshal signer to JSON: " + err.Error())
+	}
+	return signer, nil
+}
+
+// authorizeDebit - Checks the caller may debit an account: one of its owners,
+// or an operator acting for them. Accounts without owner identities can only
+// be debited by operators. Returns the owners who must sign the debit, none
+// unless the account requires every owner to sign
+func authorizeDebit(stub shim.ChaincodeStubInterface, accNumber int) (*account.Signer, []string, error) {
+	signer, err := getSigner(stub, accNumber)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(signer.Signers) == 0 {
+		err = auth.Require(stub, auth.Operator, auth.Admin)
+		if err != nil {
+			return nil, nil, errors.New("account ACC" + strconv.Itoa(accNumber) + " has no owner identity, " + err.Error())
+		}
+		return signer, nil, nil
+	}
+	if signer.CustomerID == "" && auth.Require(stub, auth.Operator, auth.Admin) != nil {
+		return nil, nil, errors.New("client identity is not an owner of account ACC" + strconv.Itoa(accNumber))
+	}
+	if signer.SigningRule != account.SigningAll {
+		return signer, nil, nil
+	}
+	return signer, signer.Signers, nil
+}
+
+// authorizeSingleDebit - Checks the caller may debit an account alone, which
+// accounts requiring every owner to sign only allow through Money
+func authorizeSingleDebit(stub shim.ChaincodeStubInterface, accNumber int) error {
+	signer, required, err := authorizeDebit(stub, accNumber)
+	if err != nil {
+		return err
+	}
+	for _, customerID := range required {
+		if customerID != signer.CustomerID {
+			return errors.New("account ACC" + strconv.Itoa(accNumber) + " requires the signature of every owner, use Money")
+		}
+	}
+	return nil
+}
+
+// newDecision - Returns a decision (approval, rejection or signature) of the caller
+func newDecision(stub shim.ChaincodeStubInterface) (*Decision, error) {
+	var err error
+	decision := &Decision{TxID: stub.GetTxID()}
+	decision.ApproverID, err = cid.GetID(stub)
+	if err != nil {
+		return nil, errors.New("cannot get client identity: " + err.Error())
+	}
+	decision.MSPID, err = cid.GetMSPID(stub)
+	if err != nil {
+		return nil, errors.New("cannot get client MSP ID: " + err.Error())
+	}
+	return decision, nil
+}
+
+// contains - Reports whether a list holds a value
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
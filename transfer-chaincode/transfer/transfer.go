@@ -5,26 +5,46 @@ package transfer
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strconv"
 
-	"github.com/hyperledger-fabric-go-chaincodes/account-chaincode/account"
-	"github.com/hyperledger/fabric/common/util"
 	"github.com/hyperledger/fabric/core/chaincode/shim"
 	"github.com/hyperledger/fabric/protos/peer"
 )
 
-// Money - Transfer money between Accounts
-// param: AccountNumber, AccountNumber, Value
+// Transfer structure with 13 properties. It is stored as TRF<txId> and is the
+// payload of the `money_transferred` event. The fee is paid by the payer on top
+// of the value and credited to the fee account. A reversal links to the
+// transfer it compensates, which keeps the reversed total and reversal IDs
+type Transfer struct {
+	ObjectType            string   `json:"docType"`
+	TxID                  string   `json:"txId"`
+	RequestID             string   `json:"requestId,omitempty"`
+	PayerAccountNumber    int      `json:"payerAccountNumber"`
+	ReceiverAccountNumber int      `json:"receiverAccountNumber"`
+	Value                 int      `json:"value"`
+	Fee                   int      `json:"fee,omitempty"`
+	FeeType               string   `json:"feeType,omitempty"`
+	FeeAccountNumber      int      `json:"feeAccountNumber,omitempty"`
+	ReversalOf            string   `json:"reversalOf,omitempty"`
+	Reason                string   `json:"reason,omitempty"`
+	ReversedValue         int      `json:"reversedValue,omitempty"`
+	Reversals             []string `json:"reversals,omitempty"`
+}
+
+// Money - Transfer money between Accounts. When a client request ID is given,
+// a repeated submission returns the original transfer instead of moving money again.
+// The caller must be allowed to debit the payer account. Transfers above the
+// approval threshold, or missing signatures of joint account owners, are
+// returned as pending transfers
+// param: AccountNumber, AccountNumber, Value, [RequestID]
 func Money(stub shim.ChaincodeStubInterface, args []string) peer.Response {
 	fmt.Println("[DEBUG] begin transfer.Money")
 
-	var payerAcc account.Account
-	var receiverAcc account.Account
-
 	// Input sanitation
-	if len(args) != 3 {
-		return shim.Error("incorrect number of arguments. 3 expected")
+	if len(args) != 3 && len(args) != 4 {
+		return shim.Error("incorrect number of arguments. 3 or 4 expected")
 	}
 	if args[0] == "" {
 		return shim.Error("1st argument must be a non-empty string")
@@ -51,75 +71,240 @@ func Money(stub shim.ChaincodeStubInterface, args []string) peer.Response {
 	}
 
 	transferValue, err := strconv.Atoi(args[2])
+	if err != nil || transferValue <= 0 {
+		return shim.Error("3rd argument must be a positive numeric string")
+	}
+
+	// Check if the request was already processed
+	requestID := ""
+	if len(args) == 4 {
+		requestID = args[3]
+		if requestID == "" {
+			return shim.Error("4th argument must be a non-empty string")
+		}
+
+		previousAsBytes, err := getTransferByRequestID(stub, requestID)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		if previousAsBytes != nil {
+			var previous Transfer
+			err = json.Unmarshal(previousAsBytes, &previous)
+			if err != nil {
+				return shim.Error("cannot unmarshal transfer to JSON: " + err.Error())
+			}
+			if previous.PayerAccountNumber != payerAccNumber || previous.ReceiverAccountNumber != receiverAccNumber || previous.Value != transferValue {
+				return shim.Error("request ID " + requestID + " was already used by a different transfer")
+			}
+
+			fmt.Println("[DEBUG] request " + requestID + " already processed by " + previous.TxID)
+			return shim.Success(previousAsBytes)
+		}
+	}
+
+	transfer := &Transfer{
+		ObjectType:            "Transfer",
+		TxID:                  stub.GetTxID(),
+		RequestID:             requestID,
+		PayerAccountNumber:    payerAccNumber,
+		ReceiverAccountNumber: receiverAccNumber,
+		Value:                 transferValue,
+	}
+
+	// Record the request ID, pointing to the transfer or its pending approval
+	if requestID != "" {
+		err = stub.PutState("REQ"+requestID, []byte(transfer.TxID))
+		if err != nil {
+			return shim.Error("failed to put state of request ID: " + err.Error())
+		}
+	}
+
+	// Check the caller may debit the payer account
+	signer, requiredSigners, err := authorizeDebit(stub, payerAccNumber)
 	if err != nil {
-		return shim.Error("3rd argument must be a numeric string")
+		return shim.Error(err.Error())
 	}
 
-	// Get payer account
-	chaincodeName := "cc-account"
-	chaincodeArgs := util.ToChaincodeArgs("GetByNumber", strconv.Itoa(payerAccNumber))
+	// High-value transfers wait for approvals, and debits of joint accounts
+	// requiring every owner wait for their signatures
+	policy, err := getApprovalPolicy(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	quorum := 0
+	if policy.requiresApproval(transferValue) {
+		quorum = policy.Quorum
+	}
+	missingSigners := 0
+	for _, customerID := range requiredSigners {
+		if customerID != signer.CustomerID {
+			missingSigners++
+		}
+	}
+	if quorum > 0 || missingSigners > 0 {
+		pendingAsBytes, err := createPendingTransfer(stub, transfer, quorum, signer, requiredSigners)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		fmt.Println("[DEBUG] end transfer.Money: pending approval")
+		return shim.Success(pendingAsBytes)
+	}
 
-	// If `channel` is empty, the caller's channel is assumed.
-	response := stub.InvokeChaincode(chaincodeName, chaincodeArgs, "")
-	if response.Status != shim.OK {
-		return shim.Error("failed to invoke `" + chaincodeName + "` chaincode: " + response.Message)
+	transferAsBytes, err := executeTransfer(stub, transfer)
+	if err != nil {
+		return shim.Error(err.Error())
 	}
-	payerAccAsBytes := response.Payload[:]
 
-	// Get receiver Account
-	chaincodeArgs = util.ToChaincodeArgs("GetByNumber", strconv.Itoa(receiverAccNumber))
-	response = stub.InvokeChaincode(chaincodeName, chaincodeArgs, "")
-	if response.Status != shim.OK {
-		return shim.Error("failed to invoke `" + chaincodeName + "` chaincode: " + response.Message)
+	fmt.Println("[DEBUG] end transfer.Money")
+	return shim.Success(transferAsBytes)
+}
+
+// executeTransfer - Moves the money of a transfer and its fee, records it as
+// TRF<txId> and notifies listeners
+func executeTransfer(stub shim.ChaincodeStubInterface, transfer *Transfer) ([]byte, error) {
+	feeSchedule, err := getFeeSchedule(stub)
+	if err != nil {
+		return nil, err
+	}
+	accNumbers := []int{transfer.PayerAccountNumber, transfer.ReceiverAccountNumber}
+	if feeSchedule != nil {
+		accNumbers = append(accNumbers, feeSchedule.FeeAccountNumber)
 	}
-	receiverAccAsBytes := response.Payload[:]
 
-	// Parse payer Account to Account object
-	err = json.Unmarshal(payerAccAsBytes, &payerAcc)
+	// Get payer, receiver and fee accounts
+	accounts, err := loadAccounts(stub, accNumbers...)
 	if err != nil {
-		return shim.Error("cannot unmarshal payer account to JSON: " + err.Error())
+		return nil, err
+	}
+	payerAcc, err := accounts.get(transfer.PayerAccountNumber)
+	if err != nil {
+		return nil, err
 	}
 
-	// Check payer Account accBalance
-	if payerAcc.AccountBalance < transferValue {
-		fmt.Println("[DEBUG] insufficient funds. payerAcc.AccountBalance = " + strconv.Itoa(payerAcc.AccountBalance))
-		return shim.Error("payer insufficient funds")
+	// Compute the fee. The fee account and accounts of fee-exempt products pay no fees
+	if feeSchedule != nil && transfer.PayerAccountNumber != feeSchedule.FeeAccountNumber {
+		product, err := accounts.product(payerAcc)
+		if err != nil {
+			return nil, err
+		}
+		if !product.FeeExempt {
+			transfer.Fee = feeSchedule.fee(transfer.Value)
+			transfer.FeeType = feeSchedule.Type
+			transfer.FeeAccountNumber = feeSchedule.FeeAccountNumber
+		}
 	}
 
-	// Parse receiver Account to Account object
-	err = json.Unmarshal(receiverAccAsBytes, &receiverAcc)
+	// Check the transfer against the payer limits
+	usage, err := checkLimits(stub, accounts, transfer.PayerAccountNumber, transfer.Value)
 	if err != nil {
-		return shim.Error("cannot unmarshal receiver account to JSON: " + err.Error())
+		return nil, err
 	}
 
-	// Transfer money
-	payerAcc.AccountBalance = payerAcc.AccountBalance - transferValue
-	receiverAcc.AccountBalance = receiverAcc.AccountBalance + transferValue
+	// Transfer money and fee, checking payer funds
+	err = accounts.move(transfer.PayerAccountNumber, transfer.ReceiverAccountNumber, transfer.Value)
+	if err != nil {
+		return nil, err
+	}
+	if transfer.Fee > 0 {
+		err = accounts.move(transfer.PayerAccountNumber, transfer.FeeAccountNumber, transfer.Fee)
+		if err != nil {
+			return nil, err
+		}
+	}
 
-	// Parse Accounts back to JSON
-	newPayerAccAsBytes, err := json.Marshal(payerAcc)
+	// Count the transfer in the payer limits
+	err = spend(stub, accounts, usage)
 	if err != nil {
-		return shim.Error("failed to marshal payer account object: " + err.Error())
+		return nil, err
 	}
-	newReceiverAccAsBytes, err := json.Marshal(receiverAcc)
+
+	// Update payer and receiver accounts
+	err = accounts.save()
 	if err != nil {
-		return shim.Error("failed to marshal receiver account object: " + err.Error())
+		return nil, err
 	}
 
-	// Update payer Account
-	chaincodeArgs = util.ToChaincodeArgs("Update", string(newPayerAccAsBytes[:]))
-	response = stub.InvokeChaincode(chaincodeName, chaincodeArgs, "")
-	if response.Status != shim.OK {
-		return shim.Error("could not update payer account: " + response.Message)
+	// Record the transfer
+	transferAsBytes, err := putTransfer(stub, transfer)
+	if err != nil {
+		return nil, err
 	}
 
-	// Update receiver Account
-	chaincodeArgs = util.ToChaincodeArgs("Update", string(newReceiverAccAsBytes[:]))
-	response = stub.InvokeChaincode(chaincodeName, chaincodeArgs, "")
-	if response.Status != shim.OK {
-		return shim.Error("could not receiver payer account: " + response.Message)
+	// Notify listeners about the transfer
+	err = stub.SetEvent("money_transferred", transferAsBytes)
+	if err != nil {
+		return nil, errors.New("failed to set event `money_transferred`: " + err.Error())
 	}
+	return transferAsBytes, nil
+}
 
-	fmt.Println("[DEBUG] end transfer.Money")
-	return shim.Success(nil)
+// GetByRequestID - Queries a transfer by the client request ID given to Money
+// param: RequestID
+func GetByRequestID(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	fmt.Println("[DEBUG] begin transfer.GetByRequestID")
+
+	// Input sanitation
+	if len(args) != 1 {
+		return shim.Error("incorrect number of arguments. 1 expected")
+	}
+	if args[0] == "" {
+		return shim.Error("1st argument must be a non-empty string")
+	}
+
+	transferAsBytes, err := getTransferByRequestID(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	} else if transferAsBytes == nil {
+		return shim.Error("transfer for request ID " + args[0] + " does not exist")
+	}
+
+	fmt.Println("[DEBUG] end transfer.GetByRequestID")
+	return shim.Success(transferAsBytes)
+}
+
+// GetTransfer - Queries a transfer by its transaction ID
+// param: TxID
+func GetTransfer(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	fmt.Println("[DEBUG] begin transfer.GetTransfer")
+
+	// Input sanitation
+	if len(args) != 1 {
+		return shim.Error("incorrect number of arguments. 1 expected")
+	}
+
+	transferAsBytes, err := stub.GetState("TRF" + args[0])
+	if err != nil {
+		return shim.Error("failed to get state of transfer " + args[0] + ": " + err.Error())
+	} else if transferAsBytes == nil {
+		return shim.Error("transfer " + args[0] + " does not exist")
+	}
+
+	fmt.Println("[DEBUG] end transfer.GetTransfer")
+	return shim.Success(transferAsBytes)
+}
+
+// getTransferByRequestID - Returns the transfer recorded for a request ID, its
+// pending transfer while it waits for approvals, or nil if there is none
+func getTransferByRequestID(stub shim.ChaincodeStubInterface, requestID string) ([]byte, error) {
+	txID, err := stub.GetState("REQ" + requestID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get state of request ID %s: %s", requestID, err.Error())
+	} else if txID == nil {
+		return nil, nil
+	}
+
+	transferAsBytes, err := stub.GetState("TRF" + string(txID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get state of transfer %s: %s", txID, err.Error())
+	} else if transferAsBytes != nil {
+		return transferAsBytes, nil
+	}
+
+	pendingAsBytes, err := stub.GetState("PND" + string(txID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get state of pending transfer %s: %s", txID, err.Error())
+	} else if pendingAsBytes == nil {
+		return nil, fmt.Errorf("transfer %s of request ID %s does not exist", txID, requestID)
+	}
+	return pendingAsBytes, nil
 }
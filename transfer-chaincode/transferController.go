@@ -11,6 +11,32 @@ peer chaincode list --instantiated -C mychannel
 ==== Transfer ====
  +++ Invokes
 peer chaincode invoke -C mychannel -n cc-transfer -c '{"Args":["Money","1","2","500"]}'
+peer chaincode invoke -C mychannel -n cc-transfer -c '{"Args":["Money","1","2","500","7f1c2a9e"]}'
+peer chaincode invoke -C mychannel -n cc-transfer -c '{"Args":["BatchTransfer","1","2","100","3","150","4","200"]}'
+peer chaincode invoke -C mychannel -n cc-transfer -c '{"Args":["CreateSchedule","1","2","300","2026-01-01","1m","","12"]}'
+peer chaincode invoke -C mychannel -n cc-transfer -c '{"Args":["CancelSchedule","<scheduleId>"]}'
+peer chaincode invoke -C mychannel -n cc-transfer -c '{"Args":["ExecuteDue"]}'
+peer chaincode invoke -C mychannel -n cc-transfer -c '{"Args":["Hold","1","2","500","2026-12-31"]}'
+peer chaincode invoke -C mychannel -n cc-transfer -c '{"Args":["Release","<escrowId>"]}'
+peer chaincode invoke -C mychannel -n cc-transfer -c '{"Args":["Refund","<escrowId>"]}'
+peer chaincode invoke -C mychannel -n cc-transfer -c '{"Args":["Reverse","<txId>","200","duplicate payment"]}'
+peer chaincode invoke -C mychannel -n cc-transfer -c '{"Args":["SetApprovalPolicy","10000","2"]}'
+peer chaincode invoke -C mychannel -n cc-transfer -c '{"Args":["Approve","<pendingTransferId>"]}'
+peer chaincode invoke -C mychannel -n cc-transfer -c '{"Args":["Reject","<pendingTransferId>"]}'
+peer chaincode invoke -C mychannel -n cc-transfer -c '{"Args":["Sign","<pendingTransferId>"]}'
+peer chaincode invoke -C mychannel -n cc-transfer -c '{"Args":["SetFeeSchedule","{\"feeAccountNumber\":99,\"type\":\"percentage\",\"basisPoints\":150,\"min\":1,\"max\":50}"]}'
+peer chaincode invoke -C mychannel -n cc-transfer -c '{"Args":["SetTransferLimits","standard","5000","10000","20"]}'
+
+ +++ Queries
+peer chaincode query -C mychannel -n cc-transfer -c '{"Args":["GetTransfer","<txId>"]}'
+peer chaincode query -C mychannel -n cc-transfer -c '{"Args":["GetByRequestID","7f1c2a9e"]}'
+peer chaincode query -C mychannel -n cc-transfer -c '{"Args":["GetSchedule","<scheduleId>"]}'
+peer chaincode query -C mychannel -n cc-transfer -c '{"Args":["GetExecutions","<scheduleId>"]}'
+peer chaincode query -C mychannel -n cc-transfer -c '{"Args":["GetEscrow","<escrowId>"]}'
+peer chaincode query -C mychannel -n cc-transfer -c '{"Args":["GetPendingTransfer","<pendingTransferId>"]}'
+peer chaincode query -C mychannel -n cc-transfer -c '{"Args":["GetFeeSchedule"]}'
+peer chaincode query -C mychannel -n cc-transfer -c '{"Args":["GetTransferLimits"]}'
+peer chaincode query -C mychannel -n cc-transfer -c '{"Args":["GetDailyUsage","1","2026-01-31"]}'
 */
 
 package main
@@ -21,36 +47,12 @@ import (
 	"github.com/hyperledger-fabric-go-chaincodes/transfer-chaincode/transfer"
 
 	"github.com/hyperledger/fabric/core/chaincode/shim"
-	"github.com/hyperledger/fabric/protos/peer"
 )
 
-// TransferController struct
-type TransferController struct {
-}
-
 //  Main
 func main() {
-	err := shim.Start(new(TransferController))
+	err := shim.Start(new(transfer.TransferController))
 	if err != nil {
 		fmt.Println("failed to initialize transfer chaincode" + err.Error())
 	}
 }
-
-// Init - initializes chaincode
-func (t *TransferController) Init(stub shim.ChaincodeStubInterface) peer.Response {
-	return shim.Success(nil)
-}
-
-// Invoke - Entry point for Invocations
-func (t *TransferController) Invoke(stub shim.ChaincodeStubInterface) peer.Response {
-	function, args := stub.GetFunctionAndParameters()
-	fmt.Println("[DEBUG] Transfer chaincode invoking " + function + " function")
-
-	// Handle different functions
-	switch function {
-	case "Money":
-		return transfer.Money(stub, args)
-	default:
-		return shim.Error("received unknown function invocation on transfer chaincode")
-	}
-}